@@ -1,23 +1,36 @@
 package aconfig
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
+	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
 )
 
 // Loader of user configuration.
 type Loader struct {
 	config  Config
 	dst     any
+	initial reflect.Value // values of dst before the first load, restored on every load.
 	parser  *structParser
 	fields  []*fieldData
 	fsys    fs.FS
 	flagSet *flag.FlagSet
 	errInit error
+
+	// mu guards the state of a single load below.
+	mu        sync.Mutex
+	foundFile string
+	profile   string
+	errs      []*FieldError
 }
 
 // Config to configure configuration loader.
@@ -29,6 +42,7 @@ type Config struct {
 
 	SkipDefaults bool // SkipDefaults set to true will not load config from 'default' tag.
 	SkipFiles    bool // SkipFiles set to true will not load config from files.
+	SkipDirs     bool // SkipDirs set to true will not load config from directories.
 	SkipEnv      bool // SkipEnv set to true will not load config from environment variables.
 	SkipFlags    bool // SkipFlags set to true will not load config from flag parameters.
 
@@ -63,6 +77,17 @@ type Config struct {
 	// DontGenerateTags disables tag generation for JSON, YAML, TOML file formats.
 	DontGenerateTags bool
 
+	// StrictTypes set to true requires values from files to match field types:
+	// a string for a string field, a number for an integer, a list for a slice.
+	// Values from env, flags and tags are strings and are parsed as usual.
+	// Types with a decoder in TypeDecoders and encoding.TextUnmarshaler accept any value.
+	StrictTypes bool
+
+	// NilPointers set to true keeps pointer fields (to primitives and to structs) nil
+	// unless a value is set by a source. Non-empty 'default' tag is also a source.
+	// Default is false: pointers to structs are always allocated.
+	NilPointers bool
+
 	// FailOnFileNotFound will stop Loader on a first not found file from Files field in this structure.
 	FailOnFileNotFound bool
 
@@ -77,9 +102,47 @@ type Config struct {
 	// (To make it easier to transfer the config file via flags.)
 	FileFlag string
 
+	// Profile is the name of the config profile, ProfileEnv and ProfileFlag have a priority over it.
+	// Profile-specific defaults can be set with `default.<profile>` tag, like `default.prod:"50"`.
+	// When a profile is set, for each loaded file 'name.ext' an optional 'name.<profile>.ext'
+	// is loaded on top of it ('.env' is overlaid by '.env.<profile>').
+	Profile string
+
+	// ProfileEnv the name of the environment variable that defines the profile (APP_PROFILE for example).
+	ProfileEnv string
+
+	// ProfileFlag the name of the flag that defines the profile passed through the CLI.
+	ProfileFlag string
+
+	// ProfileSection is a top-level key in files with per-profile sections, like 'profiles: {prod: {...}}'.
+	// Section of the current profile is applied on top of the file. Other sections are ignored.
+	ProfileSection string
+
 	// Files from which config should be loaded.
+	// Glob patterns (conf.d/*.yaml) and directories are also accepted,
+	// all the matched files are loaded in lexical order as if MergeFiles is set.
+	// For directories only files with an extension from FileDecoders are loaded.
+	// When profiles are used, matched 'name.<profile>.ext' files of a matched 'name.ext'
+	// are loaded only as overlays for the current profile.
 	Files []string
 
+	// AppName enables search of '<AppName>.<ext>' file in SearchPaths.
+	// Every extension from FileDecoders is checked, first found file is used.
+	// Explicitly set Files have a priority over the found file.
+	// Found file is reported by Loader.FoundFile.
+	AppName string
+
+	// SearchPaths where the AppName file is searched.
+	// If not set: current directory, $XDG_CONFIG_HOME/<AppName>, ~/.config/<AppName> and /etc/<AppName>.
+	SearchPaths []string
+
+	// Dirs from which config should be loaded. Each file in a directory is a single value:
+	// file name is a key and file content is a value (like Kubernetes ConfigMap or Secret volume).
+	// File name is matched against env name without EnvPrefix (DB_HOST) or a file field path (db.host).
+	// Kubelet '..data' layout is supported, hidden files are skipped.
+	// Unknown keys and not found directories are handled as for Files.
+	Dirs []string
+
 	// Envs hold the environment variable from which envs will be parsed.
 	// By default is nil and then os.Environ() will be used.
 	Envs []string
@@ -98,6 +161,23 @@ type Config struct {
 	//		".env": aconfigdotenv.New(),
 	// 	}
 	FileDecoders map[string]FileDecoder
+
+	// TypeDecoders to support types that cannot implement encoding.TextUnmarshaler.
+	// A decoder gets a raw value from a source (a string from env or flag, any value from a file)
+	// and returns a value of the key type. Used for fields, slice elements and map keys and values.
+	// Example:
+	//	TypeDecoders: map[reflect.Type]func(raw any) (any, error){
+	//		reflect.TypeOf(decimal.Decimal{}): func(raw any) (any, error) {
+	//			return decimal.NewFromString(fmt.Sprint(raw))
+	//		},
+	//	}
+	TypeDecoders map[reflect.Type]func(raw any) (any, error)
+
+	// Logger to report loading steps at debug level: files lookup, decoders,
+	// fields set by every source and ignored unknown keys.
+	// Values of fields with `secret:"true"` tag are replaced with SecretMask.
+	// Nothing is logged if nil.
+	Logger *slog.Logger
 }
 
 // FileDecoder is used to read config from files. See aconfig submodules.
@@ -107,6 +187,18 @@ type FileDecoder interface {
 	// Init(fsys fs.FS)
 }
 
+// PositionalDecoder is an optional interface for FileDecoder to report where the keys are in a file.
+// Positions are used in error messages, like: config.yaml:42:7: DB.Port: bad value "80a".
+type PositionalDecoder interface {
+	// DecodeFileWithPositions is the same as DecodeFile but also returns a position for every key.
+	// Nested keys are joined with a dot, like db.port, slice elements are indexed, like servers.0.host.
+	DecodeFileWithPositions(filename string) (map[string]any, map[string]Position, error)
+}
+
+// Position of a key in a file. Line and column start from 1.
+// Is an alias, so decoders can implement PositionalDecoder without importing this package.
+type Position = struct{ Line, Column int }
+
 // Field of the user configuration structure.
 // Done as an interface to export less things in lib.
 type Field interface {
@@ -118,6 +210,9 @@ type Field interface {
 
 	// Parent of the current node.
 	Parent() (Field, bool)
+
+	// Defaults returns profile-specific default values (`default.<profile>` tags) by profile.
+	Defaults() map[string]string
 }
 
 // LoaderFor creates a new Loader based on a given configuration structure.
@@ -126,8 +221,9 @@ func LoaderFor(dst any, cfg Config) *Loader {
 	assertStruct(dst)
 
 	l := &Loader{
-		dst:    dst,
-		config: cfg,
+		dst:     dst,
+		initial: copyValue(reflect.ValueOf(dst).Elem()),
+		config:  cfg,
 	}
 	l.init()
 	return l
@@ -171,13 +267,20 @@ func (l *Loader) init() {
 	}
 
 	if l.config.NewParser {
-		l.parser = newStructParser(l.config)
+		cfg := l.config
+		cfg.Logger = nil // defaults are logged on every load.
+		l.parser = newStructParser(cfg)
 		if err := l.parser.parseStruct(l.dst); err != nil {
 			l.errInit = err
 			return
 		}
 	} else {
-		l.fields = l.getFields(l.dst)
+		fields, err := l.getFields(l.dst)
+		if err != nil {
+			l.errInit = err
+			return
+		}
+		l.fields = fields
 	}
 
 	l.flagSet = flag.NewFlagSet(l.config.FlagPrefix, flag.ContinueOnError)
@@ -192,11 +295,20 @@ func (l *Loader) init() {
 					continue
 				}
 				if names[flagName] && !l.config.AllowDuplicates {
-					l.errInit = fmt.Errorf("duplicate flag %q", flagName)
+					l.errInit = &FieldError{
+						Path:   field.name,
+						Source: "flag",
+						Value:  flagName,
+						Err:    fmt.Errorf("%w flag %q", ErrDuplicate, flagName),
+					}
 					return
 				}
 				names[flagName] = true
-				l.flagSet.String(flagName, field.Tag("default"), field.Tag("usage"))
+				if field.variant != nil {
+					registerVariantFlags(l.flagSet, l.config, flagName, field.field.Type, field.field.Tag)
+					continue
+				}
+				l.flagSet.String(flagName, field.Tag("default"), withDefaultVariants(field.Tag("usage"), field.field.Tag))
 			}
 		}
 	}
@@ -205,6 +317,9 @@ func (l *Loader) init() {
 		// TODO: should be prefixed ?
 		l.flagSet.String(l.config.FileFlag, "", "config file param")
 	}
+	if l.config.ProfileFlag != "" {
+		l.flagSet.String(l.config.ProfileFlag, "", "config profile param")
+	}
 }
 
 // Flags returngs flag.FlagSet to create your own flags.
@@ -213,10 +328,28 @@ func (l *Loader) Flags() *flag.FlagSet {
 	return l.flagSet
 }
 
+// FoundFile returns a file found in Config.SearchPaths. Empty if nothing was found.
+func (l *Loader) FoundFile() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.foundFile
+}
+
+// Profile returns the current config profile. Empty if no profile is set.
+func (l *Loader) Profile() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.profile
+}
+
 // WalkFields iterates over configuration fields.
 // Easy way to create documentation or user-friendly help.
 func (l *Loader) WalkFields(fn func(f Field) bool) {
-	for _, f := range l.fields {
+	l.mu.Lock()
+	fields := l.fields
+	l.mu.Unlock()
+
+	for _, f := range fields {
 		if !fn(f) {
 			return
 		}
@@ -224,38 +357,223 @@ func (l *Loader) WalkFields(fn func(f Field) bool) {
 }
 
 // Load configuration into a given param.
+// It is safe to call Load many times and from many goroutines, each call loads the config from scratch.
 func (l *Loader) Load() error {
+	return l.LoadInto(l.dst)
+}
+
+// LoadInto loads configuration into dst instead of the param given to LoaderFor.
+// dst must be a pointer to the same type. Useful to get a fresh copy of the config.
+func (l *Loader) LoadInto(dst any) error {
 	if l.errInit != nil {
 		return fmt.Errorf("init loader: %w", l.errInit)
 	}
-	if err := l.loadConfig(); err != nil {
+	if reflect.TypeOf(dst) != reflect.TypeOf(l.dst) {
+		return fmt.Errorf("load config: want %T, got %T", l.dst, dst)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.loadConfig(dst); err != nil {
 		return fmt.Errorf("load config: %w", err)
 	}
 	return nil
 }
 
-func (l *Loader) loadConfig() error {
+// Check validates struct tags without loading anything:
+// required values, default values (including profile ones) and duplicated names.
+// Every problem is reported in a single *LoadError.
+func (l *Loader) Check() error {
+	if l.errInit != nil {
+		return fmt.Errorf("init loader: %w", l.errInit)
+	}
+
+	// use a fresh copy to not touch the destination.
+	typ := reflect.TypeOf(l.dst)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	fields, err := l.getFields(reflect.New(typ).Interface())
+	if err != nil {
+		return fmt.Errorf("check: %w", err)
+	}
+
+	var errs []*FieldError
+	for _, field := range fields {
+		if reload := field.field.Tag.Get("reload"); reload != "" && reload != "true" && reload != "false" {
+			errs = append(errs, &FieldError{
+				Path:   field.name,
+				Source: "tag",
+				Value:  reload,
+				Err:    fmt.Errorf("%w: 'reload' can be only 'true' or 'false', got %q", ErrBadTag, reload),
+			})
+		}
+
+		if from := field.field.Tag.Get("from"); from != "" && from != "file" {
+			errs = append(errs, &FieldError{
+				Path:   field.name,
+				Source: "tag",
+				Value:  from,
+				Err:    fmt.Errorf("%w: 'from' can be only 'file', got %q", ErrBadTag, from),
+			})
+			continue
+		}
+		if enc := field.field.Tag.Get("encoding"); enc != "" && enc != "base64" && enc != "hex" {
+			errs = append(errs, &FieldError{
+				Path:   field.name,
+				Source: "tag",
+				Value:  enc,
+				Err:    fmt.Errorf("%w: 'encoding' can be only 'base64' or 'hex', got %q", ErrBadTag, enc),
+			})
+			continue
+		}
+		if field.field.Tag.Get("from") == "file" {
+			continue // defaults are paths, files may exist only where the app runs.
+		}
+
+		sources := map[string]string{"default": field.Tag("default")}
+		for profile, value := range field.Defaults() {
+			sources["default."+profile] = value
+		}
+		for _, source := range sortedKeys(sources) {
+			value := sources[source]
+			if err := l.setFieldData(field, value); err != nil {
+				errs = append(errs, &FieldError{
+					Path:   field.name,
+					Source: source,
+					Value:  value,
+					Err:    badValueError(value, err),
+				})
+			}
+		}
+	}
+
+	if !l.config.AllowDuplicates {
+		errs = append(errs, l.checkDuplicates(fields)...)
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("check: %w", &LoadError{Errors: errs})
+	}
+	return nil
+}
+
+func (l *Loader) checkDuplicates(fields []*fieldData) []*FieldError {
+	var errs []*FieldError
+	check := func(source, prefix, tag string) {
+		names := make(map[string]bool, len(fields))
+		for _, field := range fields {
+			name := l.fullTag(prefix, field, tag)
+			if name == "" {
+				continue
+			}
+			if names[name] {
+				errs = append(errs, &FieldError{
+					Path:   field.name,
+					Source: source,
+					Value:  name,
+					Err:    fmt.Errorf("%w %s %q", ErrDuplicate, tag, name),
+				})
+			}
+			names[name] = true
+		}
+	}
+
+	if !l.config.SkipEnv {
+		check("env", l.config.EnvPrefix, "env")
+	}
+	if !l.config.SkipFlags {
+		check("flag", l.config.FlagPrefix, "flag")
+	}
+	if !l.config.SkipFiles {
+		tags := make(map[string]bool, len(l.config.FileDecoders))
+		for ext := range l.config.FileDecoders {
+			tags[strings.TrimPrefix(ext, ".")] = true
+		}
+		for _, tag := range sortedKeys(tags) {
+			check("file", "", tag)
+		}
+	}
+	return errs
+}
+
+func (l *Loader) loadConfig(dst any) error {
+	l.errs = nil
+	l.foundFile = ""
+
 	if err := l.parseFlags(); err != nil {
 		return err
 	}
-	if err := l.loadSources(); err != nil {
+	if err := l.loadSources(dst); err != nil {
 		return err
 	}
-	if err := l.checkRequired(); err != nil {
-		return err
+	l.checkRequired()
+
+	if len(l.errs) != 0 {
+		return &LoadError{Errors: l.errs}
 	}
 	return nil
 }
 
+// addError records a field error, loading continues to report all of them.
+func (l *Loader) addError(path, source string, value any, err error) {
+	l.errs = append(l.errs, &FieldError{
+		Path:   path,
+		Source: source,
+		Value:  value,
+		Err:    err,
+	})
+}
+
+func (l *Loader) hasError(path string) bool {
+	for _, err := range l.errs {
+		if err.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
 func (l *Loader) parseFlags() error {
 	// TODO: too simple?
 	if l.flagSet.Parsed() || l.config.SkipFlags {
 		return nil
 	}
-	return l.flagSet.Parse(l.config.Args)
+
+	err := l.flagSet.Parse(l.config.Args)
+	if name, ok := strings.CutPrefix(fmt.Sprint(err), "flag provided but not defined: -"); ok {
+		var known []string
+		l.flagSet.VisitAll(func(f *flag.Flag) {
+			known = append(known, f.Name)
+		})
+		return unknownError("flag", map[string]any{name: nil}, "", ErrUnknownFlag, "", known)
+	}
+	return err
 }
 
-func (l *Loader) loadSources() error {
+func (l *Loader) loadSources(dst any) error {
+	l.profile = l.resolveProfile()
+
+	// dst and fields keep values from the previous load, so start from scratch every time,
+	// values set before LoaderFor are kept.
+	reflect.ValueOf(dst).Elem().Set(copyValue(l.initial))
+
+	if l.config.NewParser {
+		parser := newStructParser(l.config)
+		parser.profile = l.profile
+		if err := parser.parseStruct(dst); err != nil {
+			return fmt.Errorf("parse struct: %w", err)
+		}
+		l.parser = parser
+	} else {
+		fields, err := l.getFields(dst)
+		if err != nil {
+			return fmt.Errorf("get fields: %w", err)
+		}
+		l.fields = fields
+	}
+
 	if !l.config.SkipDefaults {
 		if err := l.loadDefaults(); err != nil {
 			return fmt.Errorf("load defaults: %w", err)
@@ -266,6 +584,11 @@ func (l *Loader) loadSources() error {
 			return fmt.Errorf("load files: %w", err)
 		}
 	}
+	if !l.config.SkipDirs {
+		if err := l.loadDirs(); err != nil {
+			return fmt.Errorf("load dirs: %w", err)
+		}
+	}
 	if !l.config.SkipEnv {
 		if err := l.loadEnvironment(); err != nil {
 			return fmt.Errorf("load environment: %w", err)
@@ -278,28 +601,55 @@ func (l *Loader) loadSources() error {
 	}
 
 	if l.config.NewParser {
-		if err := l.parser.apply(l.dst); err != nil {
+		if err := l.collectErrors(l.parser.apply(dst)); err != nil {
 			return fmt.Errorf("apply: %w", err)
 		}
+	} else {
+		l.loadContents()
+		l.loadVariants()
+		if l.config.NilPointers {
+			l.resetNilPointers()
+		}
 	}
 	return nil
 }
 
-func (l *Loader) checkRequired() error {
-	missedFields := []string{}
+// loadContents reads files for fields with `from:"file"` tag.
+// Paths from previous sources are overridden, so only the final file must exist.
+func (l *Loader) loadContents() {
 	for _, field := range l.fields {
-		if field.isSet {
+		if field.content == nil {
 			continue
 		}
-		if field.isRequired || l.config.AllFieldRequired {
-			missedFields = append(missedFields, field.name)
+		if err := l.setFieldValue(field, field.content); err != nil {
+			l.addError(field.name, field.source, field.content, badValueError(field.content, err))
 		}
 	}
+}
 
-	if len(missedFields) == 0 {
-		return nil
+func (l *Loader) resolveProfile() string {
+	if l.config.ProfileFlag != "" {
+		if f := getActualFlag(l.config.ProfileFlag, l.flagSet); f != nil && f.Value.String() != "" {
+			return f.Value.String()
+		}
+	}
+	if l.config.ProfileEnv != "" {
+		if env, ok := getEnv(l.config.Envs)[l.config.ProfileEnv].(string); ok && env != "" {
+			return env
+		}
+	}
+	return l.config.Profile
+}
+
+func (l *Loader) checkRequired() {
+	for _, field := range l.fields {
+		if field.isSet || l.hasError(field.name) {
+			continue
+		}
+		if field.isRequired || l.config.AllFieldRequired {
+			l.addError(field.name, "", nil, ErrRequired)
+		}
 	}
-	return fmt.Errorf("fields required but not set: %s", strings.Join(missedFields, ","))
 }
 
 func (l *Loader) loadDefaults() error {
@@ -309,56 +659,260 @@ func (l *Loader) loadDefaults() error {
 
 	for _, field := range l.fields {
 		defaultValue := field.Tag("default")
+		if l.profile != "" {
+			defaultValue = defaultTag(field.field.Tag, l.profile)
+		}
+		if field.variant != nil {
+			field.variant.defaultName = defaultValue
+			continue
+		}
+		if defaultValue == "" && (isOptional(field.value.Type()) || (l.config.NilPointers && field.value.Kind() == reflect.Ptr)) {
+			continue
+		}
 		if err := l.setFieldData(field, defaultValue); err != nil {
-			return err
+			l.addError(field.name, "default", defaultValue, badValueError(defaultValue, err))
+			continue
 		}
 		field.isSet = (defaultValue != "")
+		if field.isSet {
+			field.source = "default"
+			l.logFieldSet(field, "default", defaultValue)
+		}
 	}
 	return nil
 }
 
 func (l *Loader) loadFiles() error {
+	files := l.config.Files
 	if l.config.FileFlag != "" {
-		if err := l.loadFileFlag(); err != nil {
+		var err error
+		if files, err = l.loadFileFlag(files); err != nil {
 			return err
 		}
 	}
 
-	for _, file := range l.config.Files {
-		if _, err := fs.Stat(l.fsys, file); os.IsNotExist(err) {
-			if l.config.FailOnFileNotFound {
-				return err
+	if l.config.AppName != "" {
+		l.foundFile = l.searchFile()
+		if l.foundFile != "" {
+			if l.config.MergeFiles {
+				files = append([]string{l.foundFile}, files...)
+			} else {
+				files = append(files[:len(files):len(files)], l.foundFile)
 			}
-			continue
 		}
+	}
 
-		if err := l.loadFile(file); err != nil {
+	var loaded []string
+	for i, file := range files {
+		matched, err := l.expandFile(file)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && !l.config.FailOnFileNotFound {
+				l.debug("config file not found", "file", file)
+				continue
+			}
 			return err
 		}
 
+		for _, file := range matched {
+			if err := l.loadFile(file); err != nil {
+				return err
+			}
+		}
+		loaded = append(loaded, matched...)
+
 		if !l.config.MergeFiles {
+			for _, file := range files[i+1:] {
+				l.debug("config file skipped, MergeFiles is off", "file", file)
+			}
 			break
 		}
 	}
+
+	if l.profile == "" {
+		return nil
+	}
+	for _, file := range loaded {
+		overlay := profileFile(file, l.profile)
+		if info, err := fs.Stat(l.fsys, overlay); err != nil || info.IsDir() {
+			l.debug("profile config file not found", "file", overlay, "profile", l.profile)
+			continue
+		}
+		if err := l.loadFileAs(overlay, strings.ToLower(filepath.Ext(file))); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// searchFile returns first '<AppName>.<ext>' file found in search paths.
+func (l *Loader) searchFile() string {
+	paths := l.config.SearchPaths
+	if len(paths) == 0 {
+		paths = l.defaultSearchPaths()
+	}
+
+	exts := make([]string, 0, len(l.config.FileDecoders))
+	for ext := range l.config.FileDecoders {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+
+	for _, dir := range paths {
+		for _, ext := range exts {
+			file := path.Join(dir, l.config.AppName+ext)
+			if info, err := fs.Stat(l.fsys, file); err == nil && !info.IsDir() {
+				l.debug("config file found in search paths", "file", file)
+				return file
+			}
+			l.debug("config file not found in search paths", "file", file)
+		}
+	}
+	return ""
+}
+
+func (l *Loader) defaultSearchPaths() []string {
+	envs := getEnv(l.config.Envs)
+	app := l.config.AppName
+
+	paths := []string{"."}
+	if xdg, ok := envs["XDG_CONFIG_HOME"].(string); ok && xdg != "" {
+		paths = append(paths, path.Join(xdg, app))
+	}
+	home, ok := envs["HOME"].(string)
+	if !ok || home == "" {
+		home, _ = os.UserHomeDir()
+	}
+	if home != "" {
+		paths = append(paths, path.Join(home, ".config", app))
+	}
+	return append(paths, path.Join("/etc", app))
+}
+
+// expandFile returns files for a given pattern, directory or a file.
+// Error with fs.ErrNotExist is returned when nothing is matched.
+func (l *Loader) expandFile(file string) ([]string, error) {
+	var files []string
+
+	switch info, err := fs.Stat(l.fsys, file); {
+	case err == nil && !info.IsDir():
+		return []string{file}, nil
+
+	case err == nil && info.IsDir():
+		entries, err := fs.ReadDir(l.fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			ext := strings.ToLower(filepath.Ext(entry.Name()))
+			if _, ok := l.config.FileDecoders[ext]; !ok {
+				continue
+			}
+			files = append(files, path.Join(file, entry.Name()))
+		}
+
+	case hasGlobMeta(file):
+		matches, err := fs.Glob(l.fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		files = matches
+
+	default:
+		return nil, err
+	}
+
+	res := files[:0]
+	for _, file := range files {
+		info, err := fs.Stat(l.fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() && !strings.HasPrefix(path.Base(file), ".") {
+			res = append(res, file)
+		}
+	}
+	if l.config.Profile != "" || l.config.ProfileEnv != "" || l.config.ProfileFlag != "" {
+		res = withoutProfileFiles(res)
+	}
+	if len(res) == 0 {
+		return nil, &fs.PathError{Op: "open", Path: file, Err: fs.ErrNotExist}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
 func (l *Loader) loadFile(file string) error {
-	ext := strings.ToLower(filepath.Ext(file))
+	return l.loadFileAs(file, strings.ToLower(filepath.Ext(file)))
+}
+
+// loadFileAs loads a file with a decoder for a given extension.
+func (l *Loader) loadFileAs(file, ext string) error {
 	decoder, ok := l.config.FileDecoders[ext]
 	if !ok {
 		return fmt.Errorf("file format %q is not supported", ext)
 	}
+	var err error
 
-	actualFields, err := decoder.DecodeFile(file)
+	var actualFields map[string]any
+	var positions map[string]Position
+	if dec, ok := decoder.(PositionalDecoder); ok {
+		actualFields, positions, err = dec.DecodeFileWithPositions(file)
+	} else {
+		actualFields, err = decoder.DecodeFile(file)
+	}
 	if err != nil {
 		return err
 	}
 
 	tag := decoder.Format()
+	l.debug("config file loaded", "file", file, "decoder", tag)
+
+	if l.config.ProfileSection == "" {
+		return l.applyFile(file, tag, actualFields, positions)
+	}
+
+	section, err := l.cutProfileSection(actualFields)
+	if err != nil {
+		return fmt.Errorf("file %q: %w", file, err)
+	}
+	if err := l.applyFile(file, tag, actualFields, positions); err != nil {
+		return err
+	}
+	if section == nil {
+		return nil
+	}
+	prefix := l.config.ProfileSection + "." + l.profile + "."
+	return l.applyFile(file, tag, section, subPositions(positions, prefix))
+}
+
+// cutProfileSection removes ProfileSection from the values and returns a section for the current profile.
+func (l *Loader) cutProfileSection(values map[string]interface{}) (map[string]interface{}, error) {
+	key := l.config.ProfileSection
+	raw, ok := values[key]
+	if !ok {
+		return nil, nil
+	}
+	delete(values, key)
+
+	profiles, ok := toStringMap(raw)
+	if !ok {
+		return nil, fmt.Errorf("profile section %q must be a map, got %T", key, raw)
+	}
+	raw, ok = profiles[l.profile]
+	if !ok || l.profile == "" {
+		return nil, nil
+	}
+	section, ok := toStringMap(raw)
+	if !ok {
+		return nil, fmt.Errorf("profile %q in section %q must be a map, got %T", l.profile, key, raw)
+	}
+	return section, nil
+}
 
+func (l *Loader) applyFile(file, tag string, actualFields map[string]interface{}, positions map[string]Position) error {
 	if l.config.NewParser {
-		if err := l.parser.applyLevel(tag, actualFields); err != nil {
+		err := l.parser.applyLevel(file, tag, actualFields, positions)
+		if err := l.collectErrors(err); err != nil {
 			return fmt.Errorf("apply %s: %w", tag, err)
 		}
 		return nil
@@ -378,46 +932,166 @@ func (l *Loader) loadFile(file string) error {
 			}
 		}
 
+		delete(actualFields, name)
+		if l.config.StrictTypes {
+			if path, key, err := checkStrictType(l.config.TypeDecoders, value, field.field.Type, field.field.Tag.Get("unit")); err != nil {
+				l.addError(field.name+path, filePosSource(file, positions, name+key), value, err)
+				continue
+			}
+		}
+		if field.variant != nil {
+			if err := field.variant.setFile(file, tag, name, value); err != nil {
+				l.addError(field.name, filePosSource(file, positions, name), value, err)
+			}
+			continue
+		}
 		if err := l.setFieldData(field, value); err != nil {
-			return err
+			l.addError(field.name, filePosSource(file, positions, name), value, badValueError(value, err))
+			continue
 		}
 		field.isSet = true
-		delete(actualFields, name)
+		field.source = filePosSource(file, positions, name)
+		l.logFieldSet(field, fileSource(file), value)
 	}
 
 	if !l.config.AllowUnknownFields {
-		for env := range actualFields {
-			return fmt.Errorf("unknown field in file %q: %s (see AllowUnknownFields config param)", file, env)
-		}
+		err := unknownError("", actualFields, "", ErrUnknownField, "AllowUnknownFields", l.knownNames("", tag))
+		l.collectErrors(withFileSource(err, file, positions))
+	} else {
+		logUnknown(l.config.Logger, fileSource(file), actualFields, "")
 	}
 	return nil
 }
 
-func (l *Loader) loadFileFlag() error {
+func (l *Loader) debug(msg string, args ...any) {
+	if l.config.Logger != nil {
+		l.config.Logger.Debug(msg, args...)
+	}
+}
+
+func (l *Loader) logFieldSet(field *fieldData, source string, value any) {
+	logFieldSet(l.config.Logger, field.name, source, value, isSecret(field))
+}
+
+// addUnknown records an error for every key with a prefix in values.
+func (l *Loader) addUnknown(source string, values map[string]any, prefix string, err error, param string, known []string) {
+	l.collectErrors(unknownError(source, values, prefix, err, param, known))
+}
+
+// knownNames returns all the field names for a given tag, used for suggestions.
+// For file formats parent names are also included (db for db.host).
+func (l *Loader) knownNames(prefix, tag string) []string {
+	names := make([]string, 0, len(l.fields))
+	for _, field := range l.fields {
+		name := l.fullTag(prefix, field, tag)
+		if name == "" {
+			continue
+		}
+		names = append(names, name)
+
+		if tag == "env" || tag == "flag" {
+			continue
+		}
+		for i := strings.LastIndex(name, "."); i > 0; i = strings.LastIndex(name, ".") {
+			name = name[:i]
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// collectErrors records field errors from *LoadError and returns other errors as is.
+func (l *Loader) collectErrors(err error) error {
+	var loadErr *LoadError
+	if errors.As(err, &loadErr) {
+		l.errs = append(l.errs, loadErr.Errors...)
+		return nil
+	}
+	return err
+}
+
+// loadFileFlag returns files with a file from Config.FileFlag, files are not modified.
+func (l *Loader) loadFileFlag(files []string) ([]string, error) {
 	fileFlag := getActualFlag(l.config.FileFlag, l.flagSet)
 	if fileFlag == nil {
-		return nil
+		return files, nil
 	}
 
 	configFile := fileFlag.Value.String()
 	if configFile == "" {
-		return fmt.Errorf("%s should not be empty", l.config.FileFlag)
+		return nil, fmt.Errorf("%s should not be empty", l.config.FileFlag)
 	}
 
 	if l.config.MergeFiles {
-		l.config.Files = append(l.config.Files, configFile)
+		return append(files[:len(files):len(files)], configFile), nil
+	}
+	return []string{configFile}, nil
+}
+
+func (l *Loader) loadDirs() error {
+	for _, dir := range l.config.Dirs {
+		values, err := readKeyPerFile(l.fsys, dir)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && !l.config.FailOnFileNotFound {
+				l.debug("config dir not found", "dir", dir)
+				continue
+			}
+			return err
+		}
+		l.debug("config dir loaded", "dir", dir)
+
+		if err := l.loadDir(dir, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Loader) loadDir(dir string, values map[string]any) error {
+	if l.config.NewParser {
+		if err := l.collectErrors(l.parser.applyDir(dirSource(dir), values)); err != nil {
+			return fmt.Errorf("apply dir %q: %w", dir, err)
+		}
+		return nil
+	}
+
+	for _, field := range l.fields {
+		names := []string{
+			l.fullTag("", field, "env"),
+			l.fullTag("", field, "json"),
+		}
+		for _, name := range names {
+			value, ok := values[name]
+			if !ok || name == "" {
+				continue
+			}
+			delete(values, name)
+			if err := l.setFieldData(field, value); err != nil {
+				l.addError(field.name, dirSource(dir), value, badValueError(value, err))
+				continue
+			}
+			field.isSet = true
+			field.source = dirSource(dir)
+			l.logFieldSet(field, dirSource(dir), value)
+		}
+	}
+
+	if !l.config.AllowUnknownFields {
+		known := append(l.knownNames("", "env"), l.knownNames("", "json")...)
+		l.addUnknown(dirSource(dir), values, "", ErrUnknownField, "AllowUnknownFields", known)
 	} else {
-		l.config.Files = []string{configFile}
+		logUnknown(l.config.Logger, dirSource(dir), values, "")
 	}
 	return nil
 }
 
 func (l *Loader) loadEnvironment() error {
 	actualEnvs := getEnv(l.config.Envs)
+	delete(actualEnvs, l.config.ProfileEnv)
 	dupls := make(map[string]struct{})
 
 	if l.config.NewParser {
-		if err := l.parser.applyFlat("env", actualEnvs); err != nil {
+		if err := l.collectErrors(l.parser.applyFlat("env", actualEnvs)); err != nil {
 			return fmt.Errorf("apply env: %w", err)
 		}
 		return nil
@@ -428,34 +1102,37 @@ func (l *Loader) loadEnvironment() error {
 		if envName == "" {
 			continue
 		}
-		if err := l.setField(field, envName, actualEnvs, dupls); err != nil {
-			return err
+		if field.variant != nil {
+			field.variant.takeFlat("env", envName, l.config.envDelimiter, actualEnvs)
+			continue
 		}
+		l.setField(field, "env", envName, actualEnvs, dupls)
 	}
-	return l.postEnvCheck(actualEnvs, dupls)
+	l.postEnvCheck(actualEnvs, dupls)
+	return nil
 }
 
-func (l *Loader) postEnvCheck(values map[string]any, dupls map[string]struct{}) error {
-	if l.config.AllowUnknownEnvs || l.config.EnvPrefix == "" {
-		return nil
+func (l *Loader) postEnvCheck(values map[string]any, dupls map[string]struct{}) {
+	if l.config.EnvPrefix == "" {
+		return
 	}
 	for name := range dupls {
 		delete(values, name)
 	}
-	for env := range values {
-		if strings.HasPrefix(env, l.config.EnvPrefix) {
-			return fmt.Errorf("unknown environment var %s (see AllowUnknownEnvs config param)", env)
-		}
+	if l.config.AllowUnknownEnvs {
+		logUnknown(l.config.Logger, "env", values, l.config.EnvPrefix)
+		return
 	}
-	return nil
+	l.addUnknown("env", values, l.config.EnvPrefix, ErrUnknownEnv, "AllowUnknownEnvs", l.knownNames(l.config.EnvPrefix, "env"))
 }
 
 func (l *Loader) loadFlags() error {
 	actualFlags := getFlags(l.flagSet)
+	delete(actualFlags, l.config.ProfileFlag)
 	dupls := make(map[string]struct{})
 
 	if l.config.NewParser {
-		if err := l.parser.applyFlat("flag", actualFlags); err != nil {
+		if err := l.collectErrors(l.parser.applyFlat("flag", actualFlags)); err != nil {
 			return fmt.Errorf("apply flag: %w", err)
 		}
 		return nil
@@ -466,49 +1143,54 @@ func (l *Loader) loadFlags() error {
 		if flagName == "" {
 			continue
 		}
-		if err := l.setField(field, flagName, actualFlags, dupls); err != nil {
-			return err
+		if field.variant != nil {
+			field.variant.takeFlat("flag", flagName, l.config.FlagDelimiter, actualFlags)
+			continue
 		}
+		l.setField(field, "flag", flagName, actualFlags, dupls)
 	}
-	return l.postFlagCheck(actualFlags, dupls)
+	l.postFlagCheck(actualFlags, dupls)
+	return nil
 }
 
-func (l *Loader) postFlagCheck(values map[string]any, dupls map[string]struct{}) error {
-	if l.config.AllowUnknownFlags || l.config.FlagPrefix == "" {
-		return nil
+func (l *Loader) postFlagCheck(values map[string]any, dupls map[string]struct{}) {
+	if l.config.FlagPrefix == "" {
+		return
 	}
 	for name := range dupls {
 		delete(values, name)
 	}
-	for flag := range values {
-		if strings.HasPrefix(flag, l.config.FlagPrefix) {
-			return fmt.Errorf("unknown flag %s (see AllowUnknownFlags config param)", flag)
-		}
+	if l.config.AllowUnknownFlags {
+		logUnknown(l.config.Logger, "flag", values, l.config.FlagPrefix)
+		return
 	}
-	return nil
+	l.addUnknown("flag", values, l.config.FlagPrefix, ErrUnknownFlag, "AllowUnknownFlags", l.knownNames(l.config.FlagPrefix, "flag"))
 }
 
 // TODO(cristaloleg): revisit.
-func (l *Loader) setField(field *fieldData, name string, values map[string]any, dupls map[string]struct{}) error {
+func (l *Loader) setField(field *fieldData, source, name string, values map[string]any, dupls map[string]struct{}) {
 	if !l.config.AllowDuplicates {
 		if _, ok := dupls[name]; ok {
-			return fmt.Errorf("field %q is duplicated", name)
+			l.addError(field.name, source, name, fmt.Errorf("%w name %q", ErrDuplicate, name))
+			return
 		}
 		dupls[name] = struct{}{}
 	}
 
 	val, ok := values[name]
 	if !ok {
-		return nil
+		return
 	}
 
 	if err := l.setFieldData(field, val); err != nil {
-		return err
+		l.addError(field.name, source, val, badValueError(val, err))
+		return
 	}
 
 	field.isSet = true
+	field.source = source
+	l.logFieldSet(field, source, val)
 	if !l.config.AllowDuplicates {
 		delete(values, name)
 	}
-	return nil
 }
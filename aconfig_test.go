@@ -1,13 +1,22 @@
 package aconfig
 
 import (
+	"bytes"
 	"embed"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/netip"
 	"net/url"
 	"os"
 	"reflect"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"testing/fstest"
 	"time"
@@ -384,6 +393,262 @@ func TestFileMerging(t *testing.T) {
 	mustEqual(t, cfg, want)
 }
 
+func TestFileGlobAndDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"conf.d/10-base.json":  &fstest.MapFile{Data: []byte(`{"str": "base", "http_port": 1}`)},
+		"conf.d/20-port.json":  &fstest.MapFile{Data: []byte(`{"http_port": 2}`)},
+		"conf.d/README.md":     &fstest.MapFile{Data: []byte(`not a config`)},
+		"conf.d/.hidden.json":  &fstest.MapFile{Data: []byte(`{"param": 3}`)},
+		"conf.d/sub/more.json": &fstest.MapFile{Data: []byte(`{"param": 4}`)},
+	}
+
+	f := func(file string) {
+		t.Helper()
+
+		var cfg TestConfig
+		loader := LoaderFor(&cfg, Config{
+			NewParser:          newParser,
+			SkipDefaults:       true,
+			SkipEnv:            true,
+			SkipFlags:          true,
+			FailOnFileNotFound: true,
+			Files:              []string{file},
+			FileSystem:         fsys,
+		})
+		failIfErr(t, loader.Load())
+
+		want := TestConfig{
+			Str:      "base",
+			HTTPPort: 2,
+		}
+		mustEqual(t, cfg, want)
+	}
+
+	f("conf.d/*.json")
+	f("conf.d")
+}
+
+func TestFileGlobNotFound(t *testing.T) {
+	f := func(failOnNotFound bool) error {
+		var cfg TestConfig
+		loader := LoaderFor(&cfg, Config{
+			NewParser:          newParser,
+			SkipDefaults:       true,
+			SkipEnv:            true,
+			SkipFlags:          true,
+			FailOnFileNotFound: failOnNotFound,
+			Files:              []string{"conf.d/*.json", "empty.d"},
+			FileSystem: fstest.MapFS{
+				"empty.d/README.md": &fstest.MapFile{},
+			},
+		})
+		return loader.Load()
+	}
+
+	failIfErr(t, f(false))
+	failIfOk(t, f(true))
+}
+
+func TestSearchPaths(t *testing.T) {
+	f := func(fsys fstest.MapFS, files []string, wantFile string, want TestConfig) {
+		t.Helper()
+
+		var cfg TestConfig
+		loader := LoaderFor(&cfg, Config{
+			NewParser:    newParser,
+			SkipDefaults: true,
+			SkipEnv:      true,
+			SkipFlags:    true,
+			AppName:      "app",
+			SearchPaths:  []string{".", "home/.config/app", "etc/app"},
+			Files:        files,
+			FileSystem:   fsys,
+			FileDecoders: map[string]FileDecoder{
+				".config": &jsonDecoder{},
+			},
+		})
+		failIfErr(t, loader.Load())
+		mustEqual(t, loader.FoundFile(), wantFile)
+		mustEqual(t, cfg, want)
+	}
+
+	fsys := fstest.MapFS{
+		"home/.config/app/app.config": &fstest.MapFile{Data: []byte(`{"str": "home"}`)},
+		"etc/app/app.json":            &fstest.MapFile{Data: []byte(`{"str": "etc"}`)},
+		"local.json":                  &fstest.MapFile{Data: []byte(`{"str": "local"}`)},
+	}
+	f(fsys, nil, "home/.config/app/app.config", TestConfig{Str: "home"})
+	f(fsys, []string{"local.json"}, "home/.config/app/app.config", TestConfig{Str: "local"})
+
+	delete(fsys, "home/.config/app/app.config")
+	f(fsys, nil, "etc/app/app.json", TestConfig{Str: "etc"})
+
+	delete(fsys, "etc/app/app.json")
+	f(fsys, nil, "", TestConfig{})
+}
+
+func TestDefaultSearchPaths(t *testing.T) {
+	loader := LoaderFor(&TestConfig{}, Config{
+		AppName: "app",
+		Envs:    []string{"XDG_CONFIG_HOME=/xdg", "HOME=/home/user"},
+	})
+
+	want := []string{".", "/xdg/app", "/home/user/.config/app", "/etc/app"}
+	mustEqual(t, loader.defaultSearchPaths(), want)
+}
+
+func TestProfile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config.json":      &fstest.MapFile{Data: []byte(`{"str": "base", "http_port": 1}`)},
+		"config.prod.json": &fstest.MapFile{Data: []byte(`{"http_port": 2}`)},
+		"config.dev.json":  &fstest.MapFile{Data: []byte(`{"http_port": 3}`)},
+	}
+
+	f := func(profile string, envs, args []string, want TestConfig) {
+		t.Helper()
+
+		var cfg TestConfig
+		loader := LoaderFor(&cfg, Config{
+			NewParser:    newParser,
+			SkipDefaults: true,
+			EnvPrefix:    "APP",
+			Profile:      profile,
+			ProfileEnv:   "APP_PROFILE",
+			ProfileFlag:  "profile",
+			Files:        []string{"config.json"},
+			FileSystem:   fsys,
+			Envs:         envs,
+			Args:         args,
+		})
+		failIfErr(t, loader.Load())
+		mustEqual(t, cfg, want)
+	}
+
+	f("", []string{}, []string{}, TestConfig{Str: "base", HTTPPort: 1})
+	f("unknown", []string{}, []string{}, TestConfig{Str: "base", HTTPPort: 1})
+	f("prod", []string{}, []string{}, TestConfig{Str: "base", HTTPPort: 2})
+	f("prod", []string{"APP_PROFILE=dev"}, []string{}, TestConfig{Str: "base", HTTPPort: 3})
+	f("", []string{"APP_PROFILE=dev"}, []string{"-profile=prod"}, TestConfig{Str: "base", HTTPPort: 2})
+}
+
+func TestProfileFileGlobAndDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"conf.d/app.json":      &fstest.MapFile{Data: []byte(`{"str": "base", "http_port": 1}`)},
+		"conf.d/app.prod.json": &fstest.MapFile{Data: []byte(`{"http_port": 2}`)},
+		"conf.d/app.dev.json":  &fstest.MapFile{Data: []byte(`{"str": "dev", "http_port": 3}`)},
+		"conf.d/db.json":       &fstest.MapFile{Data: []byte(`{"param": 4}`)},
+	}
+
+	f := func(file, profile string, want TestConfig) {
+		t.Helper()
+
+		var cfg TestConfig
+		loader := LoaderFor(&cfg, Config{
+			NewParser:    newParser,
+			SkipDefaults: true,
+			SkipEnv:      true,
+			SkipFlags:    true,
+			Profile:      profile,
+			ProfileEnv:   "APP_PROFILE",
+			Files:        []string{file},
+			FileSystem:   fsys,
+		})
+		failIfErr(t, loader.Load())
+		mustEqual(t, cfg, want)
+	}
+
+	f("conf.d/*.json", "", TestConfig{Str: "base", HTTPPort: 1, Param: 4})
+	f("conf.d/*.json", "prod", TestConfig{Str: "base", HTTPPort: 2, Param: 4})
+	f("conf.d", "", TestConfig{Str: "base", HTTPPort: 1, Param: 4})
+	f("conf.d", "prod", TestConfig{Str: "base", HTTPPort: 2, Param: 4})
+}
+
+func TestProfileSection(t *testing.T) {
+	f := func(profile string, want TestConfig) {
+		t.Helper()
+
+		var cfg TestConfig
+		loader := LoaderFor(&cfg, Config{
+			NewParser:      newParser,
+			SkipDefaults:   true,
+			SkipEnv:        true,
+			SkipFlags:      true,
+			Profile:        profile,
+			ProfileSection: "profiles",
+			Files:          []string{"config.json"},
+			FileSystem: fstest.MapFS{
+				"config.json": &fstest.MapFile{Data: []byte(`{
+					"str": "base",
+					"http_port": 1,
+					"profiles": {
+						"prod": {"http_port": 2},
+						"dev": {"http_port": 3, "str": "dev"}
+					}
+				}`)},
+			},
+		})
+		failIfErr(t, loader.Load())
+		mustEqual(t, loader.Profile(), profile)
+		mustEqual(t, cfg, want)
+	}
+
+	f("", TestConfig{Str: "base", HTTPPort: 1})
+	f("prod", TestConfig{Str: "base", HTTPPort: 2})
+	f("dev", TestConfig{Str: "dev", HTTPPort: 3})
+}
+
+func TestProfileDefaults(t *testing.T) {
+	type TestConfig struct {
+		PoolSize int    `default:"10" default.prod:"50" default.dev:"1" usage:"pool size"`
+		Name     string `default:"app"`
+	}
+
+	f := func(profile string, want TestConfig) {
+		t.Helper()
+
+		var cfg TestConfig
+		loader := LoaderFor(&cfg, Config{
+			NewParser: newParser,
+			SkipFiles: true,
+			SkipEnv:   true,
+			SkipFlags: true,
+			Profile:   profile,
+		})
+		failIfErr(t, loader.Load())
+		mustEqual(t, cfg, want)
+	}
+
+	f("", TestConfig{PoolSize: 10, Name: "app"})
+	f("prod", TestConfig{PoolSize: 50, Name: "app"})
+	f("dev", TestConfig{PoolSize: 1, Name: "app"})
+	f("test", TestConfig{PoolSize: 10, Name: "app"})
+
+	loader := LoaderFor(&TestConfig{}, Config{NewParser: newParser})
+
+	var builder strings.Builder
+	flags := loader.Flags()
+	flags.SetOutput(&builder)
+	flags.PrintDefaults()
+
+	want := `  -name string
+    	 (default "app")
+  -pool_size string
+    	pool size (dev "1", prod "50") (default "10")
+`
+	mustEqual(t, builder.String(), want)
+
+	if !newParser {
+		loader.WalkFields(func(f Field) bool {
+			if f.Name() == "PoolSize" {
+				mustEqual(t, f.Defaults(), map[string]string{"prod": "50", "dev": "1"})
+			} else {
+				mustEqual(t, len(f.Defaults()), 0)
+			}
+			return true
+		})
+	}
+}
+
 func TestFileFlag(t *testing.T) {
 	file1 := "testdata/config1.json"
 
@@ -393,58 +658,1145 @@ func TestFileFlag(t *testing.T) {
 
 	var cfg TestConfig
 	loader := LoaderFor(&cfg, Config{
-		NewParser:    newParser,
-		SkipDefaults: true,
-		SkipEnv:      true,
-		MergeFiles:   true,
-		FileFlag:     "file_flag",
-		Files:        []string{file1},
-		Args:         flags,
+		NewParser:    newParser,
+		SkipDefaults: true,
+		SkipEnv:      true,
+		MergeFiles:   true,
+		FileFlag:     "file_flag",
+		Files:        []string{file1},
+		Args:         flags,
+	})
+	failIfErr(t, loader.Load())
+
+	want := TestConfig{
+		Str:      "111",
+		HTTPPort: 222,
+	}
+	mustEqual(t, cfg, want)
+}
+
+func TestBadFileFlag(t *testing.T) {
+	flags := []string{
+		"-file_flag=",
+	}
+
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser:    newParser,
+		SkipDefaults: true,
+		SkipEnv:      true,
+		FileFlag:     "file_flag",
+		Args:         flags,
+	})
+	failIfOk(t, loader.Load())
+}
+
+func TestNoFileFlagValue(t *testing.T) {
+	file1 := "testdata/config1.json"
+
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser:    newParser,
+		SkipDefaults: true,
+		SkipEnv:      true,
+		FileFlag:     "file_flag",
+		Files:        []string{file1},
+		Args:         []string{}, // no file_flag
+	})
+	failIfErr(t, loader.Load())
+
+	want := TestConfig{
+		Str:      "111",
+		HTTPPort: 111,
+	}
+	mustEqual(t, cfg, want)
+}
+
+func TestLoadTwice(t *testing.T) {
+	type TestConfig struct {
+		Str      string
+		HTTPPort int
+		Ptr      *int `default:"42"`
+	}
+
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser:  newParser,
+		SkipEnv:    true,
+		MergeFiles: true,
+		FileFlag:   "file_flag",
+		Files:      []string{"testdata/config1.json"},
+		Args:       []string{"-file_flag=testdata/config2.json"},
+	})
+	failIfErr(t, loader.Load())
+	first := cfg
+	mustEqual(t, *first.Ptr, 42)
+
+	failIfErr(t, loader.Load())
+	mustEqual(t, cfg.Str, first.Str)
+	mustEqual(t, cfg.HTTPPort, 222)
+	mustEqual(t, *cfg.Ptr, 42)
+}
+
+func TestLoadRemovedKey(t *testing.T) {
+	type TestConfig struct {
+		Host string
+		Port int `default:"80"`
+	}
+
+	fsys := fstest.MapFS{
+		"config.json": &fstest.MapFile{Data: []byte(`{"host": "localhost", "port": 8080}`)},
+	}
+
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser:  newParser,
+		SkipEnv:    true,
+		SkipFlags:  true,
+		Files:      []string{"config.json"},
+		FileSystem: fsys,
+	})
+	failIfErr(t, loader.Load())
+	mustEqual(t, cfg, TestConfig{Host: "localhost", Port: 8080})
+
+	fsys["config.json"] = &fstest.MapFile{Data: []byte(`{}`)}
+	failIfErr(t, loader.Load())
+	mustEqual(t, cfg, TestConfig{Port: 80})
+}
+
+func TestLoadKeepsPrefilled(t *testing.T) {
+	type Sub struct {
+		Name string
+		Size int
+	}
+	type TestConfig struct {
+		Host string
+		Port int `default:"80"`
+		Sub  *Sub
+	}
+
+	fsys := fstest.MapFS{
+		"config.json": &fstest.MapFile{Data: []byte(`{"port": 8080, "sub": {"size": 10}}`)},
+	}
+
+	cfg := TestConfig{Host: "localhost", Sub: &Sub{Name: "sub"}}
+	loader := LoaderFor(&cfg, Config{
+		NewParser:  newParser,
+		SkipEnv:    true,
+		SkipFlags:  true,
+		Files:      []string{"config.json"},
+		FileSystem: fsys,
+	})
+	failIfErr(t, loader.Load())
+	mustEqual(t, cfg, TestConfig{Host: "localhost", Port: 8080, Sub: &Sub{Name: "sub", Size: 10}})
+
+	fsys["config.json"] = &fstest.MapFile{Data: []byte(`{}`)}
+	failIfErr(t, loader.Load())
+	mustEqual(t, cfg, TestConfig{Host: "localhost", Port: 80, Sub: &Sub{Name: "sub"}})
+}
+
+func TestLoadInto(t *testing.T) {
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser:    newParser,
+		SkipDefaults: true,
+		SkipEnv:      true,
+		SkipFlags:    true,
+		Files:        []string{"testdata/config1.json"},
+	})
+
+	var fresh TestConfig
+	failIfErr(t, loader.LoadInto(&fresh))
+	mustEqual(t, cfg, TestConfig{})
+	mustEqual(t, fresh.HTTPPort, 111)
+
+	var other struct{ Str string }
+	failIfOk(t, loader.LoadInto(&other))
+}
+
+func TestLoadConcurrent(t *testing.T) {
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser:    newParser,
+		SkipDefaults: true,
+		SkipEnv:      true,
+		Files:        []string{"testdata/config1.json"},
+		Args:         []string{},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var cfg TestConfig
+			if err := loader.LoadInto(&cfg); err != nil {
+				t.Error(err)
+				return
+			}
+			if cfg.HTTPPort != 111 {
+				t.Errorf("want 111, got %d", cfg.HTTPPort)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLogger(t *testing.T) {
+	type TestConfig struct {
+		Port     int `default:"80"`
+		Host     string
+		Password string `secret:"true"`
+	}
+
+	var logs bytes.Buffer
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser:          newParser,
+		SkipFlags:          true,
+		AllowUnknownFields: true,
+		EnvPrefix:          "APP",
+		Envs:               []string{"APP_PASSWORD=qwerty"},
+		Files:              []string{"missing.json", "config.json", "other.json"},
+		FileSystem: fstest.MapFS{
+			"config.json": &fstest.MapFile{Data: []byte(`{"host": "localhost", "unknown": 1}`)},
+			"other.json":  &fstest.MapFile{Data: []byte(`{}`)},
+		},
+		Logger: slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	})
+	failIfErr(t, loader.Load())
+	mustEqual(t, cfg, TestConfig{Port: 80, Host: "localhost", Password: "qwerty"})
+
+	for _, want := range []string{
+		`msg="config file not found" file=missing.json`,
+		`msg="config file loaded" file=config.json decoder=json`,
+		`msg="config file skipped, MergeFiles is off" file=other.json`,
+		`msg="field set" field=Port source=default value=80`,
+		`msg="field set" field=Host source="file \"config.json\"" value=localhost`,
+		`msg="field set" field=Password source=env value=***`,
+		`msg="unknown key ignored" key=unknown source="file \"config.json\""`,
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Fatalf("want %s in logs:\n%s", want, logs.String())
+		}
+	}
+	if strings.Contains(logs.String(), "qwerty") {
+		t.Fatalf("secret is logged:\n%s", logs.String())
+	}
+}
+
+type testLevel struct {
+	v int
+}
+
+var testLevelDecoder = func(raw any) (any, error) {
+	switch fmt.Sprint(raw) {
+	case "debug":
+		return testLevel{v: -1}, nil
+	case "info":
+		return testLevel{v: 0}, nil
+	default:
+		return nil, fmt.Errorf("unknown level %q", raw)
+	}
+}
+
+func TestTypeDecoders(t *testing.T) {
+	type TestConfig struct {
+		Level    testLevel `default:"debug"`
+		LevelPtr *testLevel
+		Levels   []testLevel
+		ByName   map[string]testLevel
+		Limits   map[testLevel]int
+		EnvLevel testLevel
+	}
+
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser: newParser,
+		SkipFlags: true,
+		EnvPrefix: "APP",
+		Envs:      []string{"APP_ENV_LEVEL=info"},
+		Files:     []string{"config.json"},
+		FileSystem: fstest.MapFS{
+			"config.json": &fstest.MapFile{Data: []byte(`{
+				"level_ptr": "info",
+				"levels": ["info", "debug"],
+				"by_name": {"a": "debug"},
+				"limits": {"info": 10}
+			}`)},
+		},
+		TypeDecoders: map[reflect.Type]func(raw any) (any, error){
+			reflect.TypeOf(testLevel{}): testLevelDecoder,
+		},
+	})
+	failIfErr(t, loader.Load())
+
+	want := TestConfig{
+		Level:    testLevel{v: -1},
+		LevelPtr: &testLevel{v: 0},
+		Levels:   []testLevel{{v: 0}, {v: -1}},
+		ByName:   map[string]testLevel{"a": {v: -1}},
+		Limits:   map[testLevel]int{{v: 0}: 10},
+		EnvLevel: testLevel{v: 0},
+	}
+	mustEqual(t, cfg, want)
+
+	loader = LoaderFor(&cfg, Config{
+		NewParser: newParser,
+		SkipFlags: true,
+		SkipFiles: true,
+		EnvPrefix: "APP",
+		Envs:      []string{"APP_LEVEL=trace"},
+		TypeDecoders: map[reflect.Type]func(raw any) (any, error){
+			reflect.TypeOf(testLevel{}): testLevelDecoder,
+		},
+	})
+	failIfOk(t, loader.Load())
+}
+
+func TestStdlibTypes(t *testing.T) {
+	type TestConfig struct {
+		IP       net.IP
+		IPNet    net.IPNet
+		Addr     netip.Addr
+		Prefix   netip.Prefix
+		AddrPort netip.AddrPort `default:"127.0.0.1:80"`
+		URL      *url.URL
+		URLValue url.URL
+		Regexp   *regexp.Regexp
+		Location time.Location
+		BigInt   *big.Int
+		BigFloat *big.Float
+		Mode     os.FileMode `default:"0644"`
+		FileMode os.FileMode
+		IPs      []net.IP   `env:"IPS"`
+		URLs     []*url.URL `json:"urls"`
+		Nets     map[string]netip.Prefix
+	}
+
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser: newParser,
+		SkipFlags: true,
+		EnvPrefix: "APP",
+		Envs: []string{
+			"APP_IP=10.0.0.1",
+			"APP_IPS=10.0.0.1,10.0.0.2",
+			"APP_FILE_MODE=600",
+		},
+		Files: []string{"config.json"},
+		FileSystem: fstest.MapFS{
+			"config.json": &fstest.MapFile{Data: []byte(`{
+				"ip_net": "10.0.0.0/8",
+				"addr": "::1",
+				"prefix": "10.1.0.0/16",
+				"url": "https://example.com/path",
+				"url_value": "http://localhost",
+				"regexp": "^a+$",
+				"location": "Europe/Berlin",
+				"big_int": "123456789012345678901234567890",
+				"big_float": "1.5",
+				"urls": ["http://a", "http://b"],
+				"nets": {"local": "127.0.0.0/8"}
+			}`)},
+		},
+	})
+	failIfErr(t, loader.Load())
+
+	mustEqual(t, cfg.IP.String(), "10.0.0.1")
+	mustEqual(t, cfg.IPNet.String(), "10.0.0.0/8")
+	mustEqual(t, cfg.Addr, netip.MustParseAddr("::1"))
+	mustEqual(t, cfg.Prefix, netip.MustParsePrefix("10.1.0.0/16"))
+	mustEqual(t, cfg.AddrPort, netip.MustParseAddrPort("127.0.0.1:80"))
+	mustEqual(t, cfg.URL.String(), "https://example.com/path")
+	mustEqual(t, cfg.URLValue.Host, "localhost")
+	mustEqual(t, cfg.Regexp.String(), "^a+$")
+	mustEqual(t, cfg.Location.String(), "Europe/Berlin")
+	mustEqual(t, cfg.BigInt.String(), "123456789012345678901234567890")
+	mustEqual(t, cfg.BigFloat.String(), "1.5")
+	mustEqual(t, cfg.Mode, os.FileMode(0o644))
+	mustEqual(t, cfg.FileMode, os.FileMode(0o600))
+	mustEqual(t, fmt.Sprint(cfg.IPs), "[10.0.0.1 10.0.0.2]")
+	mustEqual(t, fmt.Sprint(cfg.URLs), "[http://a http://b]")
+	mustEqual(t, cfg.Nets, map[string]netip.Prefix{"local": netip.MustParsePrefix("127.0.0.0/8")})
+}
+
+func TestTimeLayouts(t *testing.T) {
+	type TestConfig struct {
+		RFC    time.Time
+		Date   time.Time   `default:"2021-02-03" layout:"2006-01-02"`
+		Unix   time.Time   `layout:"unix"`
+		UnixMs *time.Time  `layout:"unixms"`
+		Dates  []time.Time `layout:"2006-01-02"`
+		Native time.Time
+		Events []struct {
+			At  time.Time
+			Day time.Time `layout:"2006-01-02"`
+		}
+	}
+
+	native := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser: newParser,
+		SkipFlags: true,
+		EnvPrefix: "APP",
+		Envs: []string{
+			"APP_RFC=2020-01-02T03:04:05Z",
+			"APP_UNIX_MS=1600000000123",
+			"APP_DATES=2020-01-01,2020-01-02",
+		},
+		Files: []string{"config.native"},
+		FileSystem: fstest.MapFS{
+			"config.native": &fstest.MapFile{},
+		},
+		FileDecoders: map[string]FileDecoder{
+			".native": testNativeDecoder{
+				"unix":   float64(1600000000),
+				"native": native,
+				"events": []any{map[string]any{"at": native, "day": "2021-02-03"}},
+			},
+		},
+	})
+	failIfErr(t, loader.Load())
+
+	mustEqual(t, cfg.RFC, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	mustEqual(t, cfg.Date, time.Date(2021, 2, 3, 0, 0, 0, 0, time.UTC))
+	mustEqual(t, cfg.Unix, time.Unix(1600000000, 0))
+	mustEqual(t, *cfg.UnixMs, time.UnixMilli(1600000000123))
+	mustEqual(t, cfg.Dates, []time.Time{
+		time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
+	})
+	mustEqual(t, cfg.Native, native)
+	mustEqual(t, len(cfg.Events), 1)
+	mustEqual(t, cfg.Events[0].At, native)
+	mustEqual(t, cfg.Events[0].Day, time.Date(2021, 2, 3, 0, 0, 0, 0, time.UTC))
+
+	loader = LoaderFor(&cfg, Config{
+		NewParser: newParser,
+		SkipFlags: true,
+		SkipFiles: true,
+		EnvPrefix: "APP",
+		Envs:      []string{"APP_DATE=03.02.2021"},
+	})
+	failIfOk(t, loader.Load())
+}
+
+// testNativeDecoder returns values as is, like YAML or TOML decoders do for native types.
+type testNativeDecoder map[string]any
+
+func (d testNativeDecoder) Format() string { return "native" }
+
+func (d testNativeDecoder) DecodeFile(filename string) (map[string]any, error) {
+	res := make(map[string]any, len(d))
+	for k, v := range d {
+		res[k] = v
+	}
+	return res, nil
+}
+
+func TestByteSizeAndDurations(t *testing.T) {
+	type TestConfig struct {
+		MaxBody   ByteSize `default:"1MiB"`
+		Buffer    ByteSize
+		Limits    []ByteSize
+		Cache     int64         `unit:"bytes"`
+		Retention time.Duration `default:"2w"`
+		Timeout   time.Duration
+		Intervals map[string]time.Duration
+	}
+
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser: newParser,
+		SkipFlags: true,
+		EnvPrefix: "APP",
+		Envs: []string{
+			"APP_BUFFER=512k",
+			"APP_CACHE=1.5GB",
+			"APP_TIMEOUT=PT30S",
+		},
+		Files: []string{"config.json"},
+		FileSystem: fstest.MapFS{
+			"config.json": &fstest.MapFile{Data: []byte(`{
+				"limits": ["1KB", 2048],
+				"intervals": {"gc": "1d"}
+			}`)},
+		},
+	})
+	failIfErr(t, loader.Load())
+
+	want := TestConfig{
+		MaxBody:   MiB,
+		Buffer:    512 * KiB,
+		Limits:    []ByteSize{1000, 2048},
+		Cache:     1_500_000_000,
+		Retention: 14 * 24 * time.Hour,
+		Timeout:   30 * time.Second,
+		Intervals: map[string]time.Duration{"gc": 24 * time.Hour},
+	}
+	mustEqual(t, cfg, want)
+
+	loader = LoaderFor(&cfg, Config{
+		NewParser: newParser,
+		SkipFlags: true,
+		SkipFiles: true,
+		EnvPrefix: "APP",
+		Envs:      []string{"APP_BUFFER=1XB"},
+	})
+	failIfOk(t, loader.Load())
+
+	var small struct {
+		Max uint32 `unit:"bytes"`
+	}
+	loader = LoaderFor(&small, Config{
+		NewParser: newParser,
+		SkipFlags: true,
+		SkipFiles: true,
+		EnvPrefix: "APP",
+		Envs:      []string{"APP_MAX=8GiB"},
+	})
+	err := loader.Load()
+	failIfOk(t, err)
+	if !strings.Contains(err.Error(), "overflows uint32") {
+		t.Fatalf("want overflow error, got %v", err)
+	}
+
+	f := func(file string) error {
+		t.Helper()
+
+		var cfg TestConfig
+		loader := LoaderFor(&cfg, Config{
+			NewParser: newParser,
+			SkipFlags: true,
+			SkipEnv:   true,
+			Files:     []string{"config.json"},
+			FileSystem: fstest.MapFS{
+				"config.json": &fstest.MapFile{Data: []byte(file)},
+			},
+		})
+		return loader.Load()
+	}
+	failIfErr(t, f(`{"timeout": 0}`))
+	err = f(`{"timeout": 30}`)
+	if err == nil || !strings.Contains(err.Error(), "missing unit") {
+		t.Fatalf("want missing unit error, got %v", err)
+	}
+}
+
+func TestArraysAndNestedSlices(t *testing.T) {
+	type Point struct {
+		X, Y int
+	}
+	type TestConfig struct {
+		RGB      [3]uint8 `default:"255,0,0"`
+		Coords   [2]float64
+		Retries  [3]time.Duration
+		Points   [2]Point
+		Matrix   [][]int `default:"[[1, 2], [3]]"`
+		Groups   [][]string
+		Weights  []map[string]int
+		Triplets [][3]int
+		Pairs    [2][]int
+		Tags     [2]struct{ B []string }
+		Unset    [2][]string
+	}
+
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser: newParser,
+		SkipFlags: true,
+		EnvPrefix: "APP",
+		Envs:      []string{"APP_RETRIES=1s,5s,1m"},
+		Files:     []string{"config.json"},
+		FileSystem: fstest.MapFS{
+			"config.json": &fstest.MapFile{Data: []byte(`{
+				"coords": [52.5, 13.4],
+				"points": [{"x": 1, "y": 2}, {"x": 3, "y": 4}],
+				"groups": [["a", "b"], ["c"]],
+				"weights": [{"a": 1}, {"b": 2}],
+				"triplets": [[1, 2, 3]],
+				"pairs": [[1], [2, 3]],
+				"tags": [{"b": ["x"]}, {"b": ["y", "z"]}]
+			}`)},
+		},
+	})
+	failIfErr(t, loader.Load())
+
+	want := TestConfig{
+		RGB:      [3]uint8{255, 0, 0},
+		Coords:   [2]float64{52.5, 13.4},
+		Retries:  [3]time.Duration{time.Second, 5 * time.Second, time.Minute},
+		Points:   [2]Point{{1, 2}, {3, 4}},
+		Matrix:   [][]int{{1, 2}, {3}},
+		Groups:   [][]string{{"a", "b"}, {"c"}},
+		Weights:  []map[string]int{{"a": 1}, {"b": 2}},
+		Triplets: [][3]int{{1, 2, 3}},
+		Pairs:    [2][]int{{1}, {2, 3}},
+		Tags:     [2]struct{ B []string }{{B: []string{"x"}}, {B: []string{"y", "z"}}},
+	}
+	mustEqual(t, cfg, want)
+
+	f := func(envs ...string) {
+		t.Helper()
+
+		var cfg TestConfig
+		loader := LoaderFor(&cfg, Config{
+			NewParser: newParser,
+			SkipFlags: true,
+			SkipFiles: true,
+			EnvPrefix: "APP",
+			Envs:      envs,
+		})
+		failIfOk(t, loader.Load())
+	}
+	f("APP_RGB=1,2")
+	f("APP_RGB=1,2,3,4")
+	f("APP_COORDS=1")
+	f(`APP_PAIRS=[[1]]`)
+}
+
+type testStorage interface {
+	storageName() string
+}
+
+type testS3Storage struct {
+	Bucket string `required:"true"`
+	Region string `default:"us-east-1"`
+}
+
+func (testS3Storage) storageName() string { return "s3" }
+
+type testFSStorage struct {
+	Path string
+}
+
+func (*testFSStorage) storageName() string { return "fs" }
+
+func init() {
+	RegisterVariant[testStorage]("s3", testS3Storage{})
+	RegisterVariant[testStorage]("fs", &testFSStorage{})
+}
+
+func TestVariants(t *testing.T) {
+	type TestConfig struct {
+		Storage testStorage `discriminator:"kind"`
+		Backup  testStorage `default:"fs"`
+		Mirrors []testStorage
+	}
+
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser: newParser,
+		EnvPrefix: "APP",
+		Envs:      []string{"APP_STORAGE_REGION=eu-west-1"},
+		Args:      []string{"-backup.path=/backup"},
+		Files:     []string{"config.json"},
+		FileSystem: fstest.MapFS{
+			"config.json": &fstest.MapFile{Data: []byte(`{
+				"storage": {"kind": "s3", "bucket": "data"},
+				"mirrors": [{"type": "fs", "path": "/mnt"}, {"type": "s3", "bucket": "copy"}]
+			}`)},
+		},
+	})
+	failIfErr(t, loader.Load())
+
+	want := TestConfig{
+		Storage: testS3Storage{Bucket: "data", Region: "eu-west-1"},
+		Backup:  &testFSStorage{Path: "/backup"},
+		Mirrors: []testStorage{&testFSStorage{Path: "/mnt"}, testS3Storage{Bucket: "copy", Region: "us-east-1"}},
+	}
+	mustEqual(t, cfg, want)
+
+	cfg = TestConfig{}
+	loader = LoaderFor(&cfg, Config{
+		NewParser: newParser,
+		SkipFiles: true,
+		EnvPrefix: "APP",
+		Envs: []string{
+			"APP_STORAGE_KIND=fs",
+			"APP_STORAGE_PATH=/data",
+			`APP_MIRRORS=[{"type": "s3", "bucket": "env"}]`,
+		},
+		Args: []string{"-backup.type=s3", "-backup.bucket=flag"},
+	})
+	failIfErr(t, loader.Load())
+
+	want = TestConfig{
+		Storage: &testFSStorage{Path: "/data"},
+		Backup:  testS3Storage{Bucket: "flag", Region: "us-east-1"},
+		Mirrors: []testStorage{testS3Storage{Bucket: "env", Region: "us-east-1"}},
+	}
+	mustEqual(t, cfg, want)
+
+	f := func(wantErr error, file string, envs ...string) {
+		t.Helper()
+
+		var cfg TestConfig
+		loader := LoaderFor(&cfg, Config{
+			NewParser: newParser,
+			SkipFlags: true,
+			EnvPrefix: "APP",
+			Envs:      envs,
+			Files:     []string{"config.json"},
+			FileSystem: fstest.MapFS{
+				"config.json": &fstest.MapFile{Data: []byte(file)},
+			},
+		})
+		err := loader.Load()
+		if !errors.Is(err, wantErr) {
+			t.Fatalf("want %v, got %v", wantErr, err)
+		}
+		var loadErr *LoadError
+		if !errors.As(err, &loadErr) {
+			t.Fatalf("want *LoadError, got %v", err)
+		}
+		for _, fe := range loadErr.Errors {
+			if _, ok := fe.Value.(*variantValues); ok {
+				t.Fatalf("want a raw value, got %T", fe.Value)
+			}
+		}
+	}
+	f(ErrBadValue, `{"storage": {"kind": "gcs"}}`)
+	f(ErrBadValue, `{"storage": {"bucket": "data"}}`)
+	f(ErrRequired, `{"storage": {"kind": "s3"}}`)
+	f(ErrUnknownField, `{"storage": {"kind": "fs", "bucket": "data"}}`)
+	f(ErrBadValue, `{"storage": "s3"}`)
+	f(ErrRequired, `{"mirrors": [{"type": "s3"}]}`)
+	f(ErrUnknownEnv, `{}`, "APP_STORAGE_KIND=fs", "APP_STORAGE_PAHT=/data")
+
+	cfg = TestConfig{}
+	loader = LoaderFor(&cfg, Config{
+		NewParser:    newParser,
+		SkipDefaults: true,
+		SkipFiles:    true,
+		SkipEnv:      true,
+		SkipFlags:    true,
+	})
+	failIfErr(t, loader.Load())
+	mustEqual(t, cfg, TestConfig{})
+}
+
+func TestOptional(t *testing.T) {
+	type TestConfig struct {
+		Timeout Optional[time.Duration]
+		Retries Optional[int] `default:"3"`
+		Name    Optional[string]
+		Port    Optional[int]
+		Started Optional[time.Time] `layout:"2006-01-02"`
+		Hosts   Optional[[]string]
+	}
+
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser: newParser,
+		SkipFlags: true,
+		EnvPrefix: "APP",
+		Envs:      []string{"APP_STARTED=2024-05-01"},
+		Files:     []string{"config.json"},
+		FileSystem: fstest.MapFS{
+			"config.json": &fstest.MapFile{Data: []byte(`{"timeout": "0s", "name": "", "hosts": ["a", "b"]}`)},
+		},
+	})
+	failIfErr(t, loader.Load())
+
+	mustEqual(t, cfg.Timeout.IsSet(), true)
+	mustEqual(t, cfg.Timeout.Get(), time.Duration(0))
+	mustEqual(t, cfg.Retries, NewOptional(3))
+	mustEqual(t, cfg.Name.IsSet(), true)
+	mustEqual(t, cfg.Port.IsSet(), false)
+	mustEqual(t, cfg.Port.GetOr(8080), 8080)
+	mustEqual(t, cfg.Started.Get(), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	mustEqual(t, cfg.Hosts.Get(), []string{"a", "b"})
+
+	cfg = TestConfig{}
+	loader = LoaderFor(&cfg, Config{
+		NewParser: newParser,
+		SkipFlags: true,
+		SkipFiles: true,
+		EnvPrefix: "APP",
+		Envs:      []string{"APP_PORT=abc"},
+	})
+	failIfOk(t, loader.Load())
+
+	// null is the same as a missing value, even with a default.
+	cfg = TestConfig{}
+	loader = LoaderFor(&cfg, Config{
+		NewParser: newParser,
+		SkipFlags: true,
+		SkipEnv:   true,
+		Files:     []string{"config.json"},
+		FileSystem: fstest.MapFS{
+			"config.json": &fstest.MapFile{Data: []byte(`{"port": null, "retries": null}`)},
+		},
+	})
+	failIfErr(t, loader.Load())
+	mustEqual(t, cfg.Port.IsSet(), false)
+	mustEqual(t, cfg.Retries.IsSet(), false)
+}
+
+func TestNilPointers(t *testing.T) {
+	type DB struct {
+		Host string
+		Port int
+	}
+	type Cache struct {
+		Size int
+	}
+	type TestConfig struct {
+		Timeout *int
+		Limit   *int `default:"10"`
+		Name    *string
+		DB      *DB
+		Cache   *Cache
+	}
+
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser:   newParser,
+		NilPointers: true,
+		SkipFlags:   true,
+		EnvPrefix:   "APP",
+		Envs:        []string{"APP_TIMEOUT=0"},
+		Files:       []string{"config.json"},
+		FileSystem: fstest.MapFS{
+			"config.json": &fstest.MapFile{Data: []byte(`{"db": {"host": "db"}}`)},
+		},
+	})
+	failIfErr(t, loader.Load())
+
+	mustEqual(t, *cfg.Timeout, 0)
+	mustEqual(t, *cfg.Limit, 10)
+	mustEqual(t, cfg.Name == nil, true)
+	mustEqual(t, *cfg.DB, DB{Host: "db"})
+	mustEqual(t, cfg.Cache == nil, true)
+
+	// pointers to structs are allocated by default.
+	cfg = TestConfig{}
+	loader = LoaderFor(&cfg, Config{
+		SkipFlags: true,
+		SkipFiles: true,
+		SkipEnv:   true,
+	})
+	failIfErr(t, loader.Load())
+	mustEqual(t, cfg.Name == nil, false)
+	mustEqual(t, cfg.Cache == nil, false)
+}
+
+func TestNativeTypes(t *testing.T) {
+	type DB struct {
+		Port int `json:"port"`
+	}
+	type TestConfig struct {
+		ID      int64   `json:"id"`
+		Max     uint64  `json:"max"`
+		Small   int8    `json:"small"`
+		Ratio   float32 `json:"ratio"`
+		Enabled bool    `json:"enabled"`
+		Name    string  `json:"name"`
+		Extra   any     `json:"extra"`
+		DB      DB      `json:"db"`
+	}
+
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser: newParser,
+		SkipFlags: true,
+		SkipEnv:   true,
+		Files:     []string{"config.json", "config.native"},
+		FileSystem: fstest.MapFS{
+			"config.json": &fstest.MapFile{Data: []byte(`{
+				"id": 9007199254740993,
+				"max": 18446744073709551615,
+				"small": -128,
+				"ratio": 0.5,
+				"extra": 42
+			}`)},
+			"config.native": &fstest.MapFile{},
+		},
+		MergeFiles: true,
+		FileDecoders: map[string]FileDecoder{
+			".native": testNativeDecoder{"enabled": true, "name": 123},
+		},
+	})
+	failIfErr(t, loader.Load())
+
+	want := TestConfig{
+		ID:      9007199254740993,
+		Max:     18446744073709551615,
+		Small:   -128,
+		Ratio:   0.5,
+		Enabled: true,
+		Name:    "123",
+		Extra:   int64(42),
+	}
+	mustEqual(t, cfg, want)
+
+	f := func(file, wantErr string) {
+		t.Helper()
+
+		var cfg TestConfig
+		loader := LoaderFor(&cfg, Config{
+			NewParser: newParser,
+			SkipFlags: true,
+			SkipEnv:   true,
+			Files:     []string{"config.json"},
+			FileSystem: fstest.MapFS{
+				"config.json": &fstest.MapFile{Data: []byte(file)},
+			},
+		})
+		err := loader.Load()
+		failIfOk(t, err)
+		if !strings.Contains(err.Error(), wantErr) {
+			t.Fatalf("want %q in %q", wantErr, err.Error())
+		}
+	}
+	f(`{"small": 128}`, "value 128 overflows int8")
+	f(`{"max": -1}`, "expected uint, got negative number -1")
+	f(`{"id": 1.5}`, "expected int, got fractional number 1.5")
+	f(`{"db": {"port": true}}`, "expected int, got bool")
+	f(`{"enabled": 1}`, "expected bool, got number")
+	f(`{"ratio": 1e100}`, "overflows float32")
+	f(`{"id": 123456789012345678901234567890}`, "out of range")
+
+	var bigCfg struct {
+		Big   *big.Int
+		Ratio float64
+	}
+	loader = LoaderFor(&bigCfg, Config{
+		NewParser: newParser,
+		SkipFlags: true,
+		SkipEnv:   true,
+		Files:     []string{"config.json"},
+		FileSystem: fstest.MapFS{
+			"config.json": &fstest.MapFile{Data: []byte(`{
+				"big": 123456789012345678901234567890,
+				"ratio": 123456789012345678901234567890
+			}`)},
+		},
+	})
+	failIfErr(t, loader.Load())
+	mustEqual(t, bigCfg.Big.String(), "123456789012345678901234567890")
+	mustEqual(t, bigCfg.Ratio, 1.2345678901234568e+29)
+}
+
+func TestStrictTypes(t *testing.T) {
+	type Server struct {
+		Port int
+	}
+	type DB struct {
+		Port int
+	}
+	type TestConfig struct {
+		Port    int
+		Enabled bool
+		Name    string
+		Ratio   float64
+		Timeout time.Duration
+		Hosts   []string
+		Servers []Server
+		Limits  map[string]int
+		DB      DB
+		Cache   int64 `unit:"bytes"`
+	}
+
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser:   newParser,
+		StrictTypes: true,
+		SkipFlags:   true,
+		EnvPrefix:   "APP",
+		Envs:        []string{"APP_PORT=8080"},
+		Files:       []string{"config.json"},
+		FileSystem: fstest.MapFS{
+			"config.json": &fstest.MapFile{Data: []byte(`{
+				"enabled": true,
+				"name": "app",
+				"ratio": 1,
+				"timeout": "5s",
+				"hosts": ["a", "b"],
+				"servers": [{"port": 80}],
+				"limits": {"a": 1},
+				"db": {"port": 5432},
+				"cache": "64MiB"
+			}`)},
+		},
+	})
+	failIfErr(t, loader.Load())
+
+	want := TestConfig{
+		Port:    8080,
+		Enabled: true,
+		Name:    "app",
+		Ratio:   1,
+		Timeout: 5 * time.Second,
+		Hosts:   []string{"a", "b"},
+		Servers: []Server{{Port: 80}},
+		Limits:  map[string]int{"a": 1},
+		DB:      DB{Port: 5432},
+		Cache:   64 << 20,
+	}
+	mustEqual(t, cfg, want)
+
+	cfg = TestConfig{}
+	loader = LoaderFor(&cfg, Config{
+		NewParser:   newParser,
+		StrictTypes: true,
+		SkipFlags:   true,
+		SkipEnv:     true,
+		Files:       []string{"config.json"},
+		FileSystem: fstest.MapFS{
+			"config.json": &fstest.MapFile{Data: []byte(`{
+				"port": "8080",
+				"enabled": 7,
+				"name": 1,
+				"hosts": "a,b",
+				"servers": [{"port": 80}, {"port": "81"}],
+				"limits": {"a": "1"},
+				"db": {"port": true}
+			}`)},
+		},
+	})
+	err := loader.Load()
+	failIfOk(t, err)
+
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("want *LoadError, got %v", err)
+	}
+	paths := make([]string, 0, len(loadErr.Errors))
+	for _, fe := range loadErr.Errors {
+		if !errors.Is(fe, ErrBadValue) {
+			t.Fatalf("want ErrBadValue, got %v", fe)
+		}
+		if !strings.HasPrefix(fe.Source, "config.json:") {
+			t.Fatalf("want a position for %s, got %q", fe.Path, fe.Source)
+		}
+		paths = append(paths, fe.Path)
+	}
+	sort.Strings(paths)
+	mustEqual(t, paths, []string{"DB.Port", "Enabled", "Hosts", "Limits.a", "Name", "Port", "Servers[1].Port"})
+	if msg := err.Error(); !strings.Contains(msg, "config.json:6:5: Servers[1].Port: bad value: expected int, got string") {
+		t.Fatalf("unexpected error: %s", msg)
+	}
+	if newParser {
+		// the new parser reports errors of a level sorted by path.
+		if !sort.SliceIsSorted(loadErr.Errors, func(i, j int) bool {
+			return loadErr.Errors[i].Path < loadErr.Errors[j].Path
+		}) {
+			t.Fatalf("want sorted errors, got %v", err)
+		}
+	}
+}
+
+func TestContentTags(t *testing.T) {
+	type TestConfig struct {
+		TLSCert  []byte  `from:"file"`
+		Password string  `from:"file"`
+		Port     int     `from:"file"`
+		CA       []byte  `from:"file" encoding:"base64"`
+		Key      []byte  `encoding:"base64"`
+		Token    string  `encoding:"base64"`
+		Salt     [4]byte `encoding:"hex"`
+	}
+
+	fsys := fstest.MapFS{
+		"tls/cert.pem":     &fstest.MapFile{Data: []byte("-----BEGIN CERTIFICATE-----\n")},
+		"secrets/password": &fstest.MapFile{Data: []byte("s3cret\n")},
+		"secrets/port":     &fstest.MapFile{Data: []byte("8080\n")},
+		"tls/ca.b64":       &fstest.MapFile{Data: []byte("Y2EtZGF0YQ==\n")},
+	}
+
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser:  newParser,
+		SkipFlags:  true,
+		SkipFiles:  true,
+		EnvPrefix:  "APP",
+		FileSystem: fsys,
+		Envs: []string{
+			"APP_TLS_CERT=tls/cert.pem",
+			"APP_PASSWORD=secrets/password",
+			"APP_PORT=secrets/port",
+			"APP_CA=tls/ca.b64",
+			"APP_KEY=aGVsbG8=",
+			"APP_TOKEN=dG9rZW4=",
+			"APP_SALT=deadbeef",
+		},
 	})
 	failIfErr(t, loader.Load())
 
 	want := TestConfig{
-		Str:      "111",
-		HTTPPort: 222,
+		TLSCert:  []byte("-----BEGIN CERTIFICATE-----\n"),
+		Password: "s3cret",
+		Port:     8080,
+		CA:       []byte("ca-data"),
+		Key:      []byte("hello"),
+		Token:    "token",
+		Salt:     [4]byte{0xde, 0xad, 0xbe, 0xef},
 	}
 	mustEqual(t, cfg, want)
-}
-
-func TestBadFileFlag(t *testing.T) {
-	flags := []string{
-		"-file_flag=",
-	}
 
-	var cfg TestConfig
-	loader := LoaderFor(&cfg, Config{
-		NewParser:    newParser,
-		SkipDefaults: true,
-		SkipEnv:      true,
-		FileFlag:     "file_flag",
-		Args:         flags,
-	})
-	failIfOk(t, loader.Load())
-}
-
-func TestNoFileFlagValue(t *testing.T) {
-	file1 := "testdata/config1.json"
+	f := func(envs ...string) {
+		t.Helper()
 
-	var cfg TestConfig
-	loader := LoaderFor(&cfg, Config{
-		NewParser:    newParser,
-		SkipDefaults: true,
-		SkipEnv:      true,
-		FileFlag:     "file_flag",
-		Files:        []string{file1},
-		Args:         []string{}, // no file_flag
+		var cfg TestConfig
+		loader := LoaderFor(&cfg, Config{
+			NewParser:  newParser,
+			SkipFlags:  true,
+			SkipFiles:  true,
+			EnvPrefix:  "APP",
+			FileSystem: fsys,
+			Envs:       envs,
+		})
+		failIfOk(t, loader.Load())
+	}
+	f("APP_TLS_CERT=tls/missing.pem")
+	f("APP_KEY=not base64")
+	f("APP_SALT=dead")
+	f("APP_PORT=tls/cert.pem")
+
+	// only a file from the last source is read.
+	var withDefault struct {
+		Password string `from:"file" default:"secrets/missing"`
+	}
+	loader = LoaderFor(&withDefault, Config{
+		NewParser:  newParser,
+		SkipFlags:  true,
+		SkipFiles:  true,
+		EnvPrefix:  "APP",
+		FileSystem: fsys,
+		Envs:       []string{"APP_PASSWORD=secrets/password"},
 	})
 	failIfErr(t, loader.Load())
+	mustEqual(t, withDefault.Password, "s3cret")
+
+	loader = LoaderFor(&withDefault, Config{
+		NewParser:  newParser,
+		SkipFlags:  true,
+		SkipFiles:  true,
+		EnvPrefix:  "APP",
+		FileSystem: fsys,
+		Envs:       []string{},
+	})
+	err := loader.Load()
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) || loadErr.Errors[0].Source != "default" {
+		t.Fatalf("want an error from default, got %v", err)
+	}
 
-	want := TestConfig{
-		Str:      "111",
-		HTTPPort: 111,
+	type BadConfig struct {
+		Key  []byte `encoding:"base32"`
+		Cert []byte `from:"url"`
+	}
+	err = LoaderFor(&BadConfig{}, Config{SkipFlags: true}).Check()
+	failIfOk(t, err)
+	if !errors.Is(err, ErrBadTag) {
+		t.Fatalf("want ErrBadTag, got %v", err)
 	}
-	mustEqual(t, cfg, want)
 }
 
 func TestEnv(t *testing.T) {
@@ -644,9 +1996,23 @@ func TestFailOnDuplicatedName(t *testing.T) {
 	err := loader.Load()
 	failIfOk(t, err)
 
-	if !strings.Contains(err.Error(), "is duplicated") {
+	if !errors.Is(err, ErrDuplicate) {
 		t.Fatalf("got %s", err.Error())
 	}
+
+	type NestedConfig struct {
+		FooBar string
+		Foo    Foo
+	}
+	err = LoaderFor(&NestedConfig{}, Config{
+		NewParser: newParser,
+		SkipFlags: true,
+	}).Load()
+
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Path != "Foo.Bar" {
+		t.Fatalf("want Foo.Bar path, got %v", err)
+	}
 }
 
 func TestFailOnDuplicatedFlag(t *testing.T) {
@@ -658,7 +2024,7 @@ func TestFailOnDuplicatedFlag(t *testing.T) {
 	err := LoaderFor(&Foo{}, Config{NewParser: newParser}).Load()
 	failIfOk(t, err)
 
-	want := `init loader: duplicate flag "yes"`
+	want := `init loader: flag: Baz: duplicated flag "yes"`
 	mustEqual(t, err.Error(), want)
 }
 
@@ -796,6 +2162,73 @@ func TestBadFiles(t *testing.T) {
 	f("unknown.ext")
 }
 
+func TestFileErrorPositions(t *testing.T) {
+	type TestConfig struct {
+		DB struct {
+			Host string
+			Port int
+		}
+	}
+
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser:    newParser,
+		SkipDefaults: true,
+		SkipEnv:      true,
+		SkipFlags:    true,
+		Files:        []string{"config.json"},
+		FileSystem: fstest.MapFS{
+			"config.json": &fstest.MapFile{Data: []byte(`{
+  "db": {
+    "host": "localhost",
+    "hots": "localhost"
+  }
+}`)},
+		},
+	})
+
+	err := loader.Load()
+	failIfOk(t, err)
+
+	want := `config.json:4:5: db.hots: unknown field`
+	if !strings.Contains(err.Error(), want) {
+		t.Fatalf("want %q in %q", want, err.Error())
+	}
+}
+
+func TestFileBadValuePosition(t *testing.T) {
+	type TestConfig struct {
+		DB struct {
+			Port int
+		}
+	}
+
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser:    newParser,
+		SkipDefaults: true,
+		SkipEnv:      true,
+		SkipFlags:    true,
+		Files:        []string{"config.json"},
+		FileSystem: fstest.MapFS{
+			"config.json": &fstest.MapFile{Data: []byte("{\n\t\"db\": {\"port\": \"80a\"}\n}")},
+		},
+	})
+
+	err := loader.Load()
+	failIfOk(t, err)
+
+	want := `load config: config.json:2:9: DB.Port: bad value "80a"`
+	if !strings.HasPrefix(err.Error(), want) {
+		t.Fatalf("want %q in %q", want, err.Error())
+	}
+
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) || !errors.Is(err, ErrBadValue) {
+		t.Fatalf("want *LoadError with ErrBadValue, got %v", err)
+	}
+}
+
 func TestFailOnFileNotFound(t *testing.T) {
 	f := func(filepath string) {
 		t.Helper()
@@ -816,6 +2249,68 @@ func TestFailOnFileNotFound(t *testing.T) {
 	f("testdata/not_found.json")
 }
 
+func TestDirs(t *testing.T) {
+	type TestConfig struct {
+		Token string
+		DB    struct {
+			Host string
+			Port int
+		}
+	}
+
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser:    newParser,
+		SkipDefaults: true,
+		SkipFiles:    true,
+		SkipEnv:      true,
+		SkipFlags:    true,
+		EnvPrefix:    "APP",
+		Dirs:         []string{"etc/app", "etc/secrets", "etc/not_found"},
+		FileSystem: fstest.MapFS{
+			"etc/app/db.host":                &fstest.MapFile{Data: []byte("localhost\n")},
+			"etc/app/DB_PORT":                &fstest.MapFile{Data: []byte("5432")},
+			"etc/app/.hidden":                &fstest.MapFile{Data: []byte("ignored")},
+			"etc/secrets/..data/token":       &fstest.MapFile{Data: []byte("s3cr3t")},
+			"etc/secrets/..2022_01_01/token": &fstest.MapFile{Data: []byte("old")},
+		},
+	})
+	failIfErr(t, loader.Load())
+
+	want := TestConfig{Token: "s3cr3t"}
+	want.DB.Host = "localhost"
+	want.DB.Port = 5432
+	mustEqual(t, cfg, want)
+}
+
+func TestDirsUnknownFile(t *testing.T) {
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser:    newParser,
+		SkipDefaults: true,
+		SkipFiles:    true,
+		SkipEnv:      true,
+		SkipFlags:    true,
+		Dirs:         []string{"etc/app"},
+		FileSystem: fstest.MapFS{
+			"etc/app/no_such_field": &fstest.MapFile{Data: []byte("1")},
+		},
+	})
+	failIfOk(t, loader.Load())
+
+	loader = LoaderFor(&cfg, Config{
+		NewParser:          newParser,
+		SkipDefaults:       true,
+		SkipFiles:          true,
+		SkipEnv:            true,
+		SkipFlags:          true,
+		FailOnFileNotFound: true,
+		Dirs:               []string{"etc/not_found"},
+		FileSystem:         fstest.MapFS{},
+	})
+	failIfOk(t, loader.Load())
+}
+
 func TestBadEnvs(t *testing.T) {
 	t.Setenv("TST_HTTP_PORT", "30a00")
 	defer os.Clearenv()
@@ -861,7 +2356,7 @@ func TestUnknownFields(t *testing.T) {
 	err := loader.Load()
 	failIfOk(t, err)
 
-	if !strings.Contains(err.Error(), "unknown field in file") {
+	if !errors.Is(err, ErrUnknownField) {
 		t.Fatalf("got %s", err.Error())
 	}
 }
@@ -884,11 +2379,66 @@ func TestUnknownEnvs(t *testing.T) {
 	err := loader.Load()
 	failIfOk(t, err)
 
-	if !strings.Contains(err.Error(), "unknown environment var") {
+	if !errors.Is(err, ErrUnknownEnv) {
 		t.Fatalf("got %s", err.Error())
 	}
 }
 
+func TestUnknownSuggestions(t *testing.T) {
+	type TestConfig struct {
+		DB struct {
+			Host string
+			Port int
+		}
+	}
+
+	f := func(cfg Config, want string) {
+		t.Helper()
+
+		cfg.NewParser = newParser
+		cfg.SkipDefaults = true
+		cfg.FileSystem = fstest.MapFS{
+			"config.json": &fstest.MapFile{Data: []byte(`{"db": {"hots": "localhost"}}`)},
+			"typo.json":   &fstest.MapFile{Data: []byte(`{"bd": {"host": "localhost"}}`)},
+		}
+		loader := LoaderFor(&TestConfig{}, cfg)
+		loader.Flags().SetOutput(io.Discard)
+
+		err := loader.Load()
+		failIfOk(t, err)
+
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("want %q in %q", want, err.Error())
+		}
+	}
+
+	f(Config{
+		SkipFiles: true,
+		SkipFlags: true,
+		EnvPrefix: "APP",
+		Envs:      []string{"APP_DB_HSOT=localhost"},
+	}, "unknown environment var, did you mean APP_DB_HOST?")
+
+	f(Config{
+		SkipFiles:  true,
+		SkipEnv:    true,
+		FlagPrefix: "app",
+		Args:       []string{"-app.db.prot=1"},
+	}, "flag: app.db.prot: unknown flag, did you mean app.db.port?")
+
+	f(Config{
+		SkipEnv:   true,
+		SkipFlags: true,
+		Files:     []string{"config.json"},
+	}, "db.hots: unknown field, did you mean db.host?")
+
+	f(Config{
+		SkipEnv:   true,
+		SkipFlags: true,
+		Files:     []string{"typo.json"},
+	}, "bd: unknown field, did you mean db?")
+}
+
 func TestUnknownEnvsWithEmptyPrefix(t *testing.T) {
 	t.Setenv("STR", "defined")
 	t.Setenv("UNKNOWN", "42")
@@ -933,7 +2483,7 @@ func TestUnknownFlags(t *testing.T) {
 	err := loader.Load()
 	failIfOk(t, err)
 
-	if !strings.Contains(err.Error(), "unknown flag") {
+	if !errors.Is(err, ErrUnknownFlag) {
 		t.Fatalf("got %s", err.Error())
 	}
 }
@@ -1185,22 +2735,222 @@ func TestBadRequiredTag(t *testing.T) {
 		Field string `required:"boom"`
 	}
 
-	f := func(cfg any) {
+	loader := LoaderFor(&TestConfig{}, Config{
+		NewParser: newParser,
+	})
+
+	err := loader.Load()
+	failIfOk(t, err)
+	if !errors.Is(err, ErrBadTag) {
+		t.Fatalf("want ErrBadTag, got %v", err)
+	}
+
+	err = loader.Check()
+	failIfOk(t, err)
+	if !errors.Is(err, ErrBadTag) {
+		t.Fatalf("want ErrBadTag, got %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	type TestConfig struct {
+		Port    int    `default:"80" default.dev:"80a"`
+		Timeout string `default:"1s"`
+		Retries int    `default:"many"`
+		Host    string `env:"ADDR" flag:"addr"`
+		Addr    string `flag:"addr"`
+	}
+
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		SkipFlags: true,
+		Args:      []string{},
+	})
+
+	err := loader.Check()
+	failIfOk(t, err)
+
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("want *LoadError, got %T", err)
+	}
+	mustEqual(t, len(loadErr.Errors), 3)
+	mustEqual(t, loadErr.Errors[0].Path, "Port")
+	mustEqual(t, loadErr.Errors[0].Source, "default.dev")
+	mustEqual(t, loadErr.Errors[1].Path, "Retries")
+	mustEqual(t, loadErr.Errors[1].Source, "default")
+	mustEqual(t, loadErr.Errors[2].Path, "Addr")
+	mustEqual(t, loadErr.Errors[2].Source, "env")
+	if !errors.Is(err, ErrBadValue) || !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("want ErrBadValue and ErrDuplicate, got %v", err)
+	}
+
+	// check must not touch the destination.
+	mustEqual(t, cfg, TestConfig{})
+
+	loader = LoaderFor(&cfg, Config{
+		SkipFlags:       true,
+		AllowDuplicates: true,
+		Args:            []string{},
+	})
+	err = loader.Check()
+	failIfOk(t, err)
+	if errors.Is(err, ErrDuplicate) {
+		t.Fatalf("want no ErrDuplicate, got %v", err)
+	}
+}
+
+func TestMalformedFile(t *testing.T) {
+	type TestConfig struct {
+		Sub struct {
+			A int
+		}
+		List []struct {
+			B string
+		}
+		Nums []int
+		M    map[string]int
+	}
+
+	f := func(data string) {
 		t.Helper()
 
-		defer func() {
-			t.Helper()
-			if err := recover(); err == nil {
-				t.Fatal()
+		var cfg TestConfig
+		loader := LoaderFor(&cfg, Config{
+			NewParser:    newParser,
+			SkipDefaults: true,
+			SkipEnv:      true,
+			SkipFlags:    true,
+			Files:        []string{"config.json"},
+			FileSystem: fstest.MapFS{
+				"config.json": &fstest.MapFile{Data: []byte(data)},
+			},
+		})
+		failIfOk(t, loader.Load())
+	}
+
+	f(`{"sub": [1, 2]}`)
+	f(`{"sub": {"a": {"x": 1}}}`)
+	f(`{"list": "str"}`)
+	f(`{"list": [1, 2]}`)
+	f(`{"list": [{"b": {"x": 1}}]}`)
+	f(`{"nums": {"a": 1}}`)
+	f(`{"nums": [[1]]}`)
+	f(`{"m": [1, 2]}`)
+	f(`{"m": {"a": {"b": 1}}}`)
+}
+
+func TestMalformedFileAllErrors(t *testing.T) {
+	type TestConfig struct {
+		DB struct {
+			Sub struct {
+				A int
 			}
-		}()
+			Port int
+		}
+	}
 
-		_ = LoaderFor(cfg, Config{
-			NewParser: newParser,
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		NewParser:    newParser,
+		SkipDefaults: true,
+		SkipEnv:      true,
+		SkipFlags:    true,
+		Files:        []string{"config.json"},
+		FileSystem: fstest.MapFS{
+			"config.json": &fstest.MapFile{Data: []byte(`{"db": {"sub": 5, "port": "x"}, "other": "y"}`)},
+		},
+	})
+	err := loader.Load()
+	failIfOk(t, err)
+
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("want *LoadError, got %v", err)
+	}
+	mustEqual(t, len(loadErr.Errors), 3)
+	for _, fe := range loadErr.Errors {
+		if !strings.HasPrefix(fe.Source, "config.json:1:") {
+			t.Fatalf("want a position for %s, got %q", fe.Path, fe.Source)
+		}
+	}
+}
+
+func TestNullValues(t *testing.T) {
+	type TestConfig struct {
+		S    string
+		I    int
+		U    uint
+		F    float64
+		B    bool
+		D    time.Duration
+		Any  interface{}
+		Ptr  *int
+		Sub  struct{ A int }
+		PSub *struct{ A int }
+		List []struct{ B string }
+		Nums []int
+		Strs []string
+		Arr  [2]int
+		M    map[string]int
+		Opt  Optional[struct{ M map[string]int }]
+	}
+
+	load := func(data string) (TestConfig, error) {
+		t.Helper()
+
+		var cfg TestConfig
+		loader := LoaderFor(&cfg, Config{
+			NewParser:    newParser,
+			NilPointers:  true,
+			SkipDefaults: true,
+			SkipEnv:      true,
+			SkipFlags:    true,
+			Files:        []string{"config.json"},
+			FileSystem: fstest.MapFS{
+				"config.json": &fstest.MapFile{Data: []byte(data)},
+			},
 		})
+		err := loader.Load()
+		return cfg, err
 	}
+	f := func(data string) {
+		t.Helper()
 
-	f(&TestConfig{})
+		cfg, err := load(data)
+		failIfErr(t, err)
+		mustEqual(t, cfg, TestConfig{})
+	}
+
+	f(`{"s": null}`)
+	f(`{"i": null}`)
+	f(`{"u": null}`)
+	f(`{"f": null}`)
+	f(`{"b": null}`)
+	f(`{"d": null}`)
+	f(`{"any": null}`)
+	f(`{"ptr": null}`)
+	f(`{"sub": null}`)
+	f(`{"sub": {"a": null}}`)
+	f(`{"p_sub": null}`)
+	f(`{"list": null}`)
+	f(`{"nums": null}`)
+	f(`{"strs": null}`)
+	f(`{"arr": null}`)
+	f(`{"m": null}`)
+	f(`{"opt": null}`)
+
+	cfg, err := load(`{"list": [{"b": null}]}`)
+	failIfErr(t, err)
+	mustEqual(t, cfg.List, []struct{ B string }{{}})
+
+	cfg, err = load(`{"opt": {"m": null}}`)
+	failIfErr(t, err)
+	mustEqual(t, cfg.Opt.IsSet(), true)
+	mustEqual(t, cfg.Opt.Get().M, map[string]int(nil))
+
+	_, err = load(`{"strs": ["a", null]}`)
+	failIfOk(t, err)
 }
 
 func TestMissingFieldWithRequiredTag(t *testing.T) {
@@ -1212,7 +2962,7 @@ func TestMissingFieldWithRequiredTag(t *testing.T) {
 	})
 
 	err := loader.Load()
-	want := "load config: fields required but not set: Field1"
+	want := "load config: Field1: required but not set"
 
 	if have := err.Error(); have != want {
 		t.Fatalf("got %v, want %v", err, want)
@@ -1229,13 +2979,61 @@ func TestMissingFieldsWithRequiredTag(t *testing.T) {
 	})
 
 	err := loader.Load()
-	want := "load config: fields required but not set: Field1,Field2"
+	want := "load config: 2 errors: Field1: required but not set; Field2: required but not set"
 
 	if have := err.Error(); have != want {
 		t.Fatalf("got %v, want %v", err, want)
 	}
 }
 
+func TestLoadErrorAllFields(t *testing.T) {
+	type TestConfig struct {
+		Port     int    `default:"80a"`
+		Host     string `required:"true"`
+		Timeout  time.Duration
+		Replicas int
+	}
+
+	var cfg TestConfig
+	loader := LoaderFor(&cfg, Config{
+		SkipFiles:  true,
+		EnvPrefix:  "APP",
+		FlagPrefix: "app",
+		Envs:       []string{"APP_TIMEOUT=1x", "APP_UNKNOWN=1"},
+		Args:       []string{"-app.replicas=two"},
+	})
+
+	err := loader.Load()
+	failIfOk(t, err)
+
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("want *LoadError, got %T", err)
+	}
+
+	var have []string
+	for _, fe := range loadErr.Errors {
+		have = append(have, fe.Source+" "+fe.Path)
+	}
+	want := []string{
+		"default Port",
+		"env Timeout",
+		"env APP_UNKNOWN",
+		"flag Replicas",
+		" Host",
+	}
+	mustEqual(t, have, want)
+
+	if !errors.Is(err, ErrBadValue) || !errors.Is(err, ErrUnknownEnv) || !errors.Is(err, ErrRequired) {
+		t.Fatalf("got %v", err)
+	}
+
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Path != "Port" || fieldErr.Value != "80a" {
+		t.Fatalf("got %+v", fieldErr)
+	}
+}
+
 func int32Ptr(a int32) *int32 {
 	return &a
 }
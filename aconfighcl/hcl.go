@@ -1,9 +1,13 @@
 package aconfighcl
 
 import (
+	"fmt"
 	"io/fs"
+	"strconv"
+	"strings"
 
 	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
 )
 
 // Decoder of HCL files for aconfig.
@@ -21,21 +25,66 @@ func (d *Decoder) Format() string {
 
 // DecodeFile implements aconfig.FileDecoder.
 func (d *Decoder) DecodeFile(filename string) (map[string]interface{}, error) {
+	raw, _, err := d.decodeFile(filename)
+	return raw, err
+}
+
+// DecodeFileWithPositions implements aconfig.PositionalDecoder.
+func (d *Decoder) DecodeFileWithPositions(filename string) (map[string]interface{}, map[string]struct{ Line, Column int }, error) {
+	raw, f, err := d.decodeFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	positions := map[string]struct{ Line, Column int }{}
+	if list, ok := f.Node.(*ast.ObjectList); ok {
+		collectPositions(list, "", positions)
+	}
+	return raw, positions, nil
+}
+
+func (d *Decoder) decodeFile(filename string) (map[string]interface{}, *ast.File, error) {
 	b, err := fs.ReadFile(d.fsys, filename)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	f, err := hcl.ParseBytes(b)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var raw map[string]interface{}
 	if err := hcl.DecodeObject(&raw, f); err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	return raw, f, nil
+}
+
+func collectPositions(list *ast.ObjectList, prefix string, positions map[string]struct{ Line, Column int }) {
+	for _, item := range list.Items {
+		keys := make([]string, len(item.Keys))
+		for i, key := range item.Keys {
+			keys[i] = fmt.Sprint(key.Token.Value())
+		}
+		name := prefix + strings.Join(keys, ".")
+		if len(item.Keys) > 0 {
+			pos := item.Keys[0].Pos()
+			positions[name] = struct{ Line, Column int }{pos.Line, pos.Column}
+		}
+		collectNodePositions(item.Val, name+".", positions)
+	}
+}
+
+func collectNodePositions(node ast.Node, prefix string, positions map[string]struct{ Line, Column int }) {
+	switch node := node.(type) {
+	case *ast.ObjectType:
+		collectPositions(node.List, prefix, positions)
+	case *ast.ListType:
+		for i, n := range node.List {
+			collectNodePositions(n, prefix+strconv.Itoa(i)+".", positions)
+		}
 	}
-	return raw, nil
 }
 
 // DecodeFile implements aconfig.FileDecoder.
@@ -5,6 +5,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"testing/fstest"
 
 	"github.com/cristalhq/aconfig"
 	"github.com/cristalhq/aconfig/aconfighcl"
@@ -193,3 +194,34 @@ const testfileContent = `
 "M" = "n"
 "MI" = ["q", "w"]
 `
+
+func TestDecodeFileWithPositions(t *testing.T) {
+	dec := aconfighcl.New()
+	dec.Init(fstest.MapFS{
+		"config.hcl": &fstest.MapFile{Data: []byte(`
+name = "app"
+db {
+  host = "localhost"
+  port = "80a"
+}
+servers = [{ tag = "x" }]
+`)},
+	})
+
+	_, positions, err := dec.DecodeFileWithPositions("config.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]struct{ Line, Column int }{
+		"name":          {2, 1},
+		"db":            {3, 1},
+		"db.host":       {4, 3},
+		"db.port":       {5, 3},
+		"servers":       {7, 1},
+		"servers.0.tag": {7, 14},
+	}
+	if !reflect.DeepEqual(positions, want) {
+		t.Fatalf("want %v, got %v", want, positions)
+	}
+}
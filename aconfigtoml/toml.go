@@ -2,6 +2,8 @@ package aconfigtoml
 
 import (
 	"io/fs"
+	"strconv"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 )
@@ -34,6 +36,111 @@ func (d *Decoder) DecodeFile(filename string) (map[string]interface{}, error) {
 	return raw, nil
 }
 
+// DecodeFileWithPositions implements aconfig.PositionalDecoder.
+// TOML library doesn't expose key positions, so they are found by a simple line scanner.
+func (d *Decoder) DecodeFileWithPositions(filename string) (map[string]interface{}, map[string]struct{ Line, Column int }, error) {
+	b, err := fs.ReadFile(d.fsys, filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var raw map[string]interface{}
+	if _, err := toml.Decode(string(b), &raw); err != nil {
+		return nil, nil, err
+	}
+	return raw, collectPositions(string(b)), nil
+}
+
+func collectPositions(data string) map[string]struct{ Line, Column int } {
+	positions := map[string]struct{ Line, Column int }{}
+	tableArrays := map[string]int{}
+	prefix := ""
+	multiline := ""
+	arrayDepth := 0
+
+	for i, line := range strings.Split(data, "\n") {
+		text := strings.TrimLeft(line, " \t")
+		pos := struct{ Line, Column int }{i + 1, len(line) - len(text) + 1}
+
+		switch {
+		case multiline != "":
+			if strings.Contains(text, multiline) {
+				multiline = ""
+			}
+
+		case arrayDepth > 0:
+			arrayDepth += strings.Count(text, "[") - strings.Count(text, "]")
+
+		case text == "" || text[0] == '#':
+
+		case strings.HasPrefix(text, "[["):
+			end := strings.Index(text, "]]")
+			if end < 0 {
+				continue
+			}
+			name := parseKey(text[2:end])
+			if _, ok := positions[name]; !ok {
+				positions[name] = pos
+			}
+			idx := tableArrays[name]
+			tableArrays[name]++
+			prefix = name + "." + strconv.Itoa(idx) + "."
+
+		case text[0] == '[':
+			end := strings.IndexByte(text, ']')
+			if end < 0 {
+				continue
+			}
+			name := parseKey(text[1:end])
+			positions[name] = pos
+			prefix = name + "."
+
+		default:
+			eq := strings.IndexByte(text, '=')
+			if eq < 0 {
+				continue
+			}
+			positions[prefix+parseKey(text[:eq])] = pos
+
+			value := strings.TrimSpace(text[eq+1:])
+			for _, delim := range []string{`"""`, `'''`} {
+				if strings.HasPrefix(value, delim) && !strings.Contains(value[3:], delim) {
+					multiline = delim
+				}
+			}
+			if strings.HasPrefix(value, "[") {
+				arrayDepth = strings.Count(value, "[") - strings.Count(value, "]")
+			}
+		}
+	}
+	return positions
+}
+
+// parseKey normalizes TOML key: a . "b.c" -> a.b.c
+func parseKey(key string) string {
+	var parts []string
+	for key = strings.TrimSpace(key); key != ""; key = strings.TrimSpace(key) {
+		var part string
+		switch key[0] {
+		case '"', '\'':
+			end := strings.IndexByte(key[1:], key[0])
+			if end < 0 {
+				return strings.Join(append(parts, key), ".")
+			}
+			part, key = key[1:end+1], key[end+2:]
+		default:
+			end := strings.IndexByte(key, '.')
+			if end < 0 {
+				end = len(key)
+			}
+			part, key = strings.TrimSpace(key[:end]), key[end:]
+		}
+		parts = append(parts, part)
+		key = strings.TrimPrefix(strings.TrimSpace(key), ".")
+	}
+	return strings.Join(parts, ".")
+}
+
 // DecodeFile implements aconfig.FileDecoder.
 func (d *Decoder) Init(fsys fs.FS) {
 	d.fsys = fsys
@@ -5,6 +5,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"testing/fstest"
 
 	"github.com/cristalhq/aconfig"
 	"github.com/cristalhq/aconfig/aconfigtoml"
@@ -199,3 +200,49 @@ z = [ 1, 2, 3 ]
 	m = "n"
 	b = "boo"
 `
+
+func TestDecodeFileWithPositions(t *testing.T) {
+	dec := aconfigtoml.New()
+	dec.Init(fstest.MapFS{
+		"config.toml": &fstest.MapFile{Data: []byte(`
+name = "app"
+desc = """
+fake = 1
+"""
+ports = [
+  1,
+  2,
+]
+
+[db]
+  host = "localhost"
+  "port" = "80a"
+
+[[servers]]
+name = "a"
+
+[[servers]]
+name = "b"
+`)},
+	})
+
+	_, positions, err := dec.DecodeFileWithPositions("config.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]struct{ Line, Column int }{
+		"name":           {2, 1},
+		"desc":           {3, 1},
+		"ports":          {6, 1},
+		"db":             {11, 1},
+		"db.host":        {12, 3},
+		"db.port":        {13, 3},
+		"servers":        {15, 1},
+		"servers.0.name": {16, 1},
+		"servers.1.name": {19, 1},
+	}
+	if !reflect.DeepEqual(positions, want) {
+		t.Fatalf("want %v, got %v", want, positions)
+	}
+}
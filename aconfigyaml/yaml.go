@@ -2,6 +2,7 @@ package aconfigyaml
 
 import (
 	"io/fs"
+	"strconv"
 
 	"gopkg.in/yaml.v3"
 )
@@ -34,6 +35,54 @@ func (d *Decoder) DecodeFile(filename string) (map[string]interface{}, error) {
 	return raw, nil
 }
 
+// DecodeFileWithPositions implements aconfig.PositionalDecoder.
+func (d *Decoder) DecodeFileWithPositions(filename string) (map[string]interface{}, map[string]struct{ Line, Column int }, error) {
+	f, err := d.fsys.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	var node yaml.Node
+	if err := yaml.NewDecoder(f).Decode(&node); err != nil {
+		return nil, nil, err
+	}
+
+	var raw map[string]interface{}
+	if err := node.Decode(&raw); err != nil {
+		return nil, nil, err
+	}
+
+	positions := map[string]struct{ Line, Column int }{}
+	collectPositions(&node, "", positions)
+	return raw, positions, nil
+}
+
+func collectPositions(node *yaml.Node, prefix string, positions map[string]struct{ Line, Column int }) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.AliasNode:
+		for _, n := range node.Content {
+			collectPositions(n, prefix, positions)
+		}
+		if node.Alias != nil {
+			collectPositions(node.Alias, prefix, positions)
+		}
+
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			name := prefix + key.Value
+			positions[name] = struct{ Line, Column int }{key.Line, key.Column}
+			collectPositions(value, name+".", positions)
+		}
+
+	case yaml.SequenceNode:
+		for i, n := range node.Content {
+			collectPositions(n, prefix+strconv.Itoa(i)+".", positions)
+		}
+	}
+}
+
 // DecodeFile implements aconfig.FileDecoder.
 func (d *Decoder) Init(fsys fs.FS) {
 	d.fsys = fsys
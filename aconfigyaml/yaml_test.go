@@ -5,6 +5,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"testing/fstest"
 
 	"github.com/cristalhq/aconfig"
 	"github.com/cristalhq/aconfig/aconfigyaml"
@@ -219,3 +220,34 @@ m: "n"
 
 MI: ["q", "w"]
 `
+
+func TestDecodeFileWithPositions(t *testing.T) {
+	dec := aconfigyaml.New()
+	dec.Init(fstest.MapFS{
+		"config.yaml": &fstest.MapFile{Data: []byte(`
+db:
+  host: localhost
+  port: 80a
+servers:
+  - name: a
+    tags: [x]
+`)},
+	})
+
+	_, positions, err := dec.DecodeFileWithPositions("config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]struct{ Line, Column int }{
+		"db":             {2, 1},
+		"db.host":        {3, 3},
+		"db.port":        {4, 3},
+		"servers":        {5, 1},
+		"servers.0.name": {6, 5},
+		"servers.0.tags": {7, 5},
+	}
+	if !reflect.DeepEqual(positions, want) {
+		t.Fatalf("want %v, got %v", want, positions)
+	}
+}
@@ -0,0 +1,232 @@
+package aconfig
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ByteSize is a size in bytes that can be set with units: 512, 64MiB, 1.5GB, 512k.
+//
+// Units are case-insensitive:
+//   - B for bytes;
+//   - KB, MB, GB, TB, PB are powers of 1000;
+//   - KiB, MiB, GiB, TiB, PiB and short K, M, G, T, P are powers of 1024.
+type ByteSize uint64
+
+// Byte sizes.
+const (
+	Byte ByteSize = 1
+	KiB           = 1024 * Byte
+	MiB           = 1024 * KiB
+	GiB           = 1024 * MiB
+	TiB           = 1024 * GiB
+	PiB           = 1024 * TiB
+)
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	size, err := parseByteSize(string(text))
+	if err != nil {
+		return err
+	}
+	*b = ByteSize(size)
+	return nil
+}
+
+// String returns the size with the largest binary unit that keeps it exact: 1536 is 1536B, 2048 is 2KiB.
+func (b ByteSize) String() string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	i := 0
+	for b != 0 && b%1024 == 0 && i < len(units)-1 {
+		b /= 1024
+		i++
+	}
+	return strconv.FormatUint(uint64(b), 10) + units[i]
+}
+
+var byteSizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"pb":  1e15,
+	"k":   1 << 10,
+	"kib": 1 << 10,
+	"m":   1 << 20,
+	"mib": 1 << 20,
+	"g":   1 << 30,
+	"gib": 1 << 30,
+	"t":   1 << 40,
+	"tib": 1 << 40,
+	"p":   1 << 50,
+	"pib": 1 << 50,
+}
+
+func parseByteSize(s string) (uint64, error) {
+	str := strings.TrimSpace(s)
+	i := strings.IndexFunc(str, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i == -1 {
+		i = len(str)
+	}
+	num, unit := str[:i], strings.ToLower(strings.TrimSpace(str[i:]))
+
+	mult, ok := byteSizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit %q in %q", unit, s)
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	size := n * mult
+	if size >= math.MaxUint64 {
+		return 0, fmt.Errorf("size %q overflows", s)
+	}
+	if size != math.Trunc(size) {
+		return 0, fmt.Errorf("size %q is not a whole number of bytes", s)
+	}
+	return uint64(size), nil
+}
+
+// parseDuration parses time.ParseDuration format with days and weeks (7d, 2w1d12h)
+// and ISO 8601 durations (PT30S, P1DT12H).
+func parseDuration(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+	str := strings.TrimSpace(s)
+
+	neg := false
+	if len(str) > 0 && (str[0] == '-' || str[0] == '+') {
+		neg = str[0] == '-'
+		str = str[1:]
+	}
+
+	var d time.Duration
+	var err error
+	if strings.HasPrefix(str, "P") {
+		d, err = parseISODuration(str)
+	} else {
+		d, err = parseDaysDuration(str)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+// parseDaysDuration parses a duration where d (day) and w (week) units are allowed.
+func parseDaysDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, errors.New("empty duration")
+	}
+
+	var total time.Duration
+	for s != "" {
+		i := strings.IndexFunc(s, func(r rune) bool {
+			return (r < '0' || r > '9') && r != '.'
+		})
+		if i <= 0 {
+			return 0, errors.New("missing number")
+		}
+		j := strings.IndexFunc(s[i:], func(r rune) bool {
+			return (r >= '0' && r <= '9') || r == '.'
+		})
+		if j == -1 {
+			j = len(s) - i
+		}
+		num, unit := s[:i], s[i:i+j]
+		s = s[i+j:]
+
+		var part time.Duration
+		switch unit {
+		case "d", "w":
+			n, err := strconv.ParseFloat(num, 64)
+			if err != nil {
+				return 0, err
+			}
+			day := 24 * time.Hour
+			if unit == "w" {
+				day *= 7
+			}
+			part = time.Duration(n * float64(day))
+		default:
+			d, err := time.ParseDuration(num + unit)
+			if err != nil {
+				return 0, err
+			}
+			part = d
+		}
+		total += part
+	}
+	return total, nil
+}
+
+// parseISODuration parses ISO 8601 duration: P[nW][nD][T[nH][nM][nS]].
+// Years and months are not supported as they don't have a fixed length.
+func parseISODuration(s string) (time.Duration, error) {
+	s = strings.TrimPrefix(s, "P")
+	if s == "" || s == "T" {
+		return 0, errors.New("empty duration")
+	}
+
+	units := map[byte]time.Duration{
+		'W': 7 * 24 * time.Hour,
+		'D': 24 * time.Hour,
+	}
+	timeUnits := map[byte]time.Duration{
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+	}
+
+	var total time.Duration
+	inTime := false
+	for s != "" {
+		if s[0] == 'T' {
+			if inTime {
+				return 0, errors.New("duplicated T")
+			}
+			inTime = true
+			s = s[1:]
+			continue
+		}
+
+		i := strings.IndexFunc(s, func(r rune) bool {
+			return (r < '0' || r > '9') && r != '.' && r != ','
+		})
+		if i <= 0 {
+			return 0, errors.New("missing number")
+		}
+		n, err := strconv.ParseFloat(strings.Replace(s[:i], ",", ".", 1), 64)
+		if err != nil {
+			return 0, err
+		}
+
+		unit, ok := units[s[i]]
+		if inTime {
+			unit, ok = timeUnits[s[i]]
+		}
+		if !ok {
+			if !inTime && (s[i] == 'Y' || s[i] == 'M') {
+				return 0, errors.New("years and months are not supported")
+			}
+			return 0, fmt.Errorf("unknown unit %q", s[i])
+		}
+		total += time.Duration(n * float64(unit))
+		s = s[i+1:]
+	}
+	return total, nil
+}
@@ -0,0 +1,85 @@
+package aconfig
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_parseByteSize(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    uint64
+		wantErr bool
+	}{
+		{"512", 512, false},
+		{"512B", 512, false},
+		{"512k", 512 << 10, false},
+		{"64MiB", 64 << 20, false},
+		{"64 mib", 64 << 20, false},
+		{"1.5GB", 1_500_000_000, false},
+		{"2TiB", 2 << 40, false},
+		{"1.5G", 3 << 29, false},
+		{"", 0, true},
+		{"MB", 0, true},
+		{"10XB", 0, true},
+		{"1.5B", 0, true},
+		{"100000PiB", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseByteSize(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseByteSize(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseByteSize(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestByteSizeString(t *testing.T) {
+	mustEqual(t, ByteSize(0).String(), "0B")
+	mustEqual(t, ByteSize(1536).String(), "1536B")
+	mustEqual(t, (2 * KiB).String(), "2KiB")
+	mustEqual(t, (64 * MiB).String(), "64MiB")
+	mustEqual(t, (3 * PiB).String(), "3PiB")
+}
+
+func Test_parseDuration(t *testing.T) {
+	const day = 24 * time.Hour
+
+	tests := []struct {
+		s       string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"1h30m", 90 * time.Minute, false},
+		{"7d", 7 * day, false},
+		{"2w", 14 * day, false},
+		{"1w2d12h", 9*day + 12*time.Hour, false},
+		{"1.5d", 36 * time.Hour, false},
+		{"-1d", -day, false},
+		{"PT30S", 30 * time.Second, false},
+		{"PT1.5S", 1500 * time.Millisecond, false},
+		{"P1DT12H", day + 12*time.Hour, false},
+		{"P2W", 14 * day, false},
+		{"PT1H30M", 90 * time.Minute, false},
+		{"P1M", 0, true},
+		{"P1Y", 0, true},
+		{"P", 0, true},
+		{"PT", 0, true},
+		{"1x", 0, true},
+		{"d", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDuration(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDuration(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseDuration(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
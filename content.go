@@ -0,0 +1,84 @@
+package aconfig
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"strings"
+)
+
+// readContent returns content for a value of a field with `from` and `encoding` tags.
+// With from:"file" the value is a path of a file to read from fsys,
+// with encoding:"base64" or encoding:"hex" the value (or the file content) is decoded.
+// Returns false if there are no such tags or the value is empty.
+func readContent(fsys fs.FS, from, encoding string, value any) ([]byte, bool, error) {
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+	if (from == "" && encoding == "") || value == nil || value == "" {
+		return nil, false, nil
+	}
+
+	var data []byte
+	switch from {
+	case "":
+		data = []byte(fmt.Sprint(value))
+	case "file":
+		name := fmt.Sprint(value)
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, true, fmt.Errorf("read file %q: %w", name, err)
+		}
+		data = b
+	default:
+		return nil, true, fmt.Errorf("%w: 'from' can be only 'file', got %q", ErrBadTag, from)
+	}
+
+	switch encoding {
+	case "":
+		return data, true, nil
+	case "base64":
+		b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, true, fmt.Errorf("decode base64: %w", err)
+		}
+		return b, true, nil
+	case "hex":
+		b, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, true, fmt.Errorf("decode hex: %w", err)
+		}
+		return b, true, nil
+	default:
+		return nil, true, fmt.Errorf("%w: 'encoding' can be only 'base64' or 'hex', got %q", ErrBadTag, encoding)
+	}
+}
+
+// contentValue returns content as a value of a byte slice or a byte array type,
+// for other types it's a string to parse, trailing newline of a file is trimmed.
+func contentValue(data []byte, from string, typ reflect.Type) (any, error) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch {
+	case typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8:
+		return reflect.ValueOf(data).Convert(typ).Interface(), nil
+
+	case typ.Kind() == reflect.Array && typ.Elem().Kind() == reflect.Uint8:
+		if len(data) != typ.Len() {
+			return nil, fmt.Errorf("want %d bytes, got %d", typ.Len(), len(data))
+		}
+		arr := reflect.New(typ).Elem()
+		reflect.Copy(arr, reflect.ValueOf(data))
+		return arr.Interface(), nil
+	}
+
+	s := string(data)
+	if from == "file" {
+		s = strings.TrimRight(s, "\r\n")
+	}
+	return s, nil
+}
@@ -0,0 +1,308 @@
+package aconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// normalizeNumbers replaces json.Number in a decoded JSON value with int64,
+// uint64 for integers above math.MaxInt64 or float64, so big integers keep precision.
+// Integers that don't fit into 64 bits are kept as json.Number for types like *big.Int.
+func normalizeNumbers(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		if n, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return n
+		}
+		if !strings.ContainsAny(v.String(), ".eE") {
+			return v
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for k, item := range v {
+			v[k] = normalizeNumbers(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = normalizeNumbers(item)
+		}
+	}
+	return value
+}
+
+// convertPrimitive converts a value to a bool, integer or float type with overflow checks.
+func convertPrimitive(value any, to reflect.Type) (any, error) {
+	res := reflect.New(to).Elem()
+	switch to.Kind() {
+	case reflect.Bool:
+		v, err := toBool(value)
+		if err != nil {
+			return nil, err
+		}
+		res.SetBool(v)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := toInt64(value)
+		if err != nil {
+			return nil, err
+		}
+		if res.OverflowInt(v) {
+			return nil, fmt.Errorf("value %d overflows %v", v, to)
+		}
+		res.SetInt(v)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v, err := toUint64(value)
+		if err != nil {
+			return nil, err
+		}
+		if res.OverflowUint(v) {
+			return nil, fmt.Errorf("value %d overflows %v", v, to)
+		}
+		res.SetUint(v)
+
+	case reflect.Float32, reflect.Float64:
+		v, err := toFloat64(value)
+		if err != nil {
+			return nil, err
+		}
+		if res.OverflowFloat(v) {
+			return nil, fmt.Errorf("value %v overflows %v", v, to)
+		}
+		res.SetFloat(v)
+
+	default:
+		return nil, fmt.Errorf("type %v isn't a primitive", to)
+	}
+	return res.Interface(), nil
+}
+
+// isNumeric reports whether a type is a bool, integer or float.
+func isNumeric(typ reflect.Type) bool {
+	return typ.Kind() >= reflect.Bool && typ.Kind() <= reflect.Float64
+}
+
+// toInt64 converts a number from a file decoder or a string from env, flags and tags to int64.
+func toInt64(value any) (int64, error) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, fmt.Errorf("value %d overflows int64", rv.Uint())
+		}
+		return int64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) {
+			return 0, fmt.Errorf("expected int, got fractional number %v", f)
+		}
+		if f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, fmt.Errorf("value %v overflows int64", f)
+		}
+		return int64(f), nil
+	case reflect.String:
+		return strconv.ParseInt(rv.String(), 0, 64)
+	}
+	return 0, fmt.Errorf("expected int, got %s", kindName(value))
+}
+
+// toUint64 converts a number from a file decoder or a string to uint64.
+func toUint64(value any) (uint64, error) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.Int() < 0 {
+			return 0, fmt.Errorf("expected uint, got negative number %d", rv.Int())
+		}
+		return uint64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) {
+			return 0, fmt.Errorf("expected uint, got fractional number %v", f)
+		}
+		if f < 0 {
+			return 0, fmt.Errorf("expected uint, got negative number %v", f)
+		}
+		if f >= math.MaxUint64 {
+			return 0, fmt.Errorf("value %v overflows uint64", f)
+		}
+		return uint64(f), nil
+	case reflect.String:
+		return strconv.ParseUint(rv.String(), 0, 64)
+	}
+	return 0, fmt.Errorf("expected uint, got %s", kindName(value))
+}
+
+// toFloat64 converts a number from a file decoder or a string to float64.
+func toFloat64(value any) (float64, error) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.String:
+		return strconv.ParseFloat(rv.String(), 64)
+	}
+	return 0, fmt.Errorf("expected float, got %s", kindName(value))
+}
+
+// toBool converts a bool from a file decoder or a string to bool.
+func toBool(value any) (bool, error) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.String:
+		return strconv.ParseBool(rv.String())
+	}
+	return false, fmt.Errorf("expected bool, got %s", kindName(value))
+}
+
+// isNumber reports whether a value is a number from a file decoder.
+func isNumber(value any) bool {
+	if _, ok := value.(json.Number); ok {
+		return true
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// kindName returns a name of a value kind for errors: number, bool, object, list.
+func kindName(value any) string {
+	if value == nil {
+		return "null"
+	}
+	if isNumber(value) {
+		return "number"
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.String:
+		return "string"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		return "list"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// checkStrictType checks that a value from a file matches a type without conversions,
+// returned path and key are relative to the value: [1].Port and [1].port for a list of structs,
+// the path is made of field names and the key of file keys.
+// Types with a decoder or encoding.TextUnmarshaler accept any value,
+// numbers with unit:"bytes" tag also accept strings like 64MiB.
+func checkStrictType(decoders map[reflect.Type]func(raw any) (any, error), value any, typ reflect.Type, unit string) (path, key string, err error) {
+	if value == nil {
+		return "", "", nil
+	}
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if isOptional(typ) {
+		typ = typ.Field(0).Type
+	}
+	if hasTypeDecoder(decoders, typ) || isTextUnmarshaler(typ) || typ.Kind() == reflect.Interface {
+		return "", "", nil
+	}
+
+	mismatch := func(want string) (string, string, error) {
+		return "", "", fmt.Errorf("%w: expected %s, got %s", ErrBadValue, want, kindName(value))
+	}
+	rv := reflect.ValueOf(value)
+	if unit == "bytes" && isNumeric(typ) && typ.Kind() != reflect.Bool && rv.Kind() == reflect.String {
+		return "", "", nil
+	}
+
+	switch kind := typ.Kind(); {
+	case kind == reflect.Bool:
+		if rv.Kind() != reflect.Bool {
+			return mismatch("bool")
+		}
+	case kind >= reflect.Int && kind <= reflect.Int64:
+		if !isNumber(value) {
+			return mismatch("int")
+		}
+	case kind >= reflect.Uint && kind <= reflect.Uintptr:
+		if !isNumber(value) {
+			return mismatch("uint")
+		}
+	case kind == reflect.Float32 || kind == reflect.Float64:
+		if !isNumber(value) {
+			return mismatch("float")
+		}
+	case kind == reflect.String:
+		if rv.Kind() != reflect.String || isNumber(value) {
+			return mismatch("string")
+		}
+
+	case kind == reflect.Slice || kind == reflect.Array:
+		if kind == reflect.Slice && typ.Elem().Kind() == reflect.Uint8 && rv.Kind() == reflect.String {
+			return "", "", nil
+		}
+		if rv.Kind() != reflect.Slice {
+			return mismatch("list")
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if path, key, err := checkStrictType(decoders, rv.Index(i).Interface(), typ.Elem(), unit); err != nil {
+				index := fmt.Sprintf("[%d]", i)
+				return index + path, index + key, err
+			}
+		}
+
+	case kind == reflect.Map || kind == reflect.Struct:
+		if rv.Kind() != reflect.Map {
+			return mismatch("object")
+		}
+		for _, mapKey := range rv.MapKeys() {
+			name := fmt.Sprint(mapKey.Interface())
+			itemName, itemType, itemUnit := name, typ, unit
+			if kind == reflect.Map {
+				itemType = typ.Elem()
+			} else {
+				field, ok := structField(typ, name)
+				if !ok {
+					continue // unknown fields are reported separately.
+				}
+				itemName, itemType, itemUnit = field.Name, field.Type, field.Tag.Get("unit")
+			}
+			if path, key, err := checkStrictType(decoders, rv.MapIndex(mapKey).Interface(), itemType, itemUnit); err != nil {
+				return "." + itemName + path, "." + name + key, err
+			}
+		}
+	}
+	return "", "", nil
+}
+
+// structField returns a struct field for a key from a file, false if there is no such field.
+func structField(typ reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		generated := strings.ToLower(strings.Join(splitNameByWords(field.Name), "_"))
+		if strings.EqualFold(field.Name, key) || generated == key {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
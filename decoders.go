@@ -0,0 +1,202 @@
+package aconfig
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTypeDecoders are used for types without a decoder in Config.TypeDecoders.
+var defaultTypeDecoders = map[reflect.Type]func(raw any) (any, error){
+	reflect.TypeOf(net.IP{}): func(raw any) (any, error) {
+		ip := net.ParseIP(fmt.Sprint(raw))
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", raw)
+		}
+		return ip, nil
+	},
+	reflect.TypeOf(net.IPNet{}): func(raw any) (any, error) {
+		_, ipNet, err := net.ParseCIDR(fmt.Sprint(raw))
+		if err != nil {
+			return nil, err
+		}
+		return *ipNet, nil
+	},
+	reflect.TypeOf(netip.Addr{}): func(raw any) (any, error) {
+		return netip.ParseAddr(fmt.Sprint(raw))
+	},
+	reflect.TypeOf(netip.Prefix{}): func(raw any) (any, error) {
+		return netip.ParsePrefix(fmt.Sprint(raw))
+	},
+	reflect.TypeOf(netip.AddrPort{}): func(raw any) (any, error) {
+		return netip.ParseAddrPort(fmt.Sprint(raw))
+	},
+	reflect.TypeOf(&url.URL{}): func(raw any) (any, error) {
+		return url.Parse(fmt.Sprint(raw))
+	},
+	reflect.TypeOf(&regexp.Regexp{}): func(raw any) (any, error) {
+		return regexp.Compile(fmt.Sprint(raw))
+	},
+	reflect.TypeOf(&time.Location{}): func(raw any) (any, error) {
+		return time.LoadLocation(fmt.Sprint(raw))
+	},
+	reflect.TypeOf(&big.Int{}): func(raw any) (any, error) {
+		s := fmt.Sprint(raw)
+		n, ok := new(big.Int).SetString(s, 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %q", s)
+		}
+		return n, nil
+	},
+	reflect.TypeOf(&big.Float{}): func(raw any) (any, error) {
+		s := fmt.Sprint(raw)
+		f, ok := new(big.Float).SetString(s)
+		if !ok {
+			return nil, fmt.Errorf("invalid float %q", s)
+		}
+		return f, nil
+	},
+	reflect.TypeOf(time.Duration(0)): func(raw any) (any, error) {
+		if d, ok := raw.(time.Duration); ok {
+			return d, nil
+		}
+		// a bare number from a file is ambiguous (seconds? nanoseconds?), only 0 is allowed like in "0".
+		if isNumber(raw) {
+			if n, err := toInt64(raw); err != nil || n != 0 {
+				return nil, fmt.Errorf("invalid duration %v: missing unit", raw)
+			}
+			return time.Duration(0), nil
+		}
+		return parseDuration(fmt.Sprint(raw))
+	},
+	reflect.TypeOf(ByteSize(0)): func(raw any) (any, error) {
+		if isNumber(raw) {
+			n, err := toUint64(raw)
+			return ByteSize(n), err
+		}
+		size, err := parseByteSize(fmt.Sprint(raw))
+		return ByteSize(size), err
+	},
+	reflect.TypeOf(os.FileMode(0)): func(raw any) (any, error) {
+		// numbers from files are taken as is, strings are octal: 644, 0644 or 0o644.
+		if isNumber(raw) {
+			n, err := toUint64(raw)
+			if err != nil || n > math.MaxUint32 {
+				return nil, fmt.Errorf("invalid file mode %v", raw)
+			}
+			return os.FileMode(n), nil
+		}
+		s := strings.TrimPrefix(strings.TrimPrefix(fmt.Sprint(raw), "0o"), "0O")
+		mode, err := strconv.ParseUint(s, 8, 32)
+		if err != nil {
+			return nil, err
+		}
+		return os.FileMode(mode), nil
+	},
+}
+
+// typeDecoder returns a decoder for a type or for a pointer to it, the result has the given type.
+func typeDecoder(decoders map[reflect.Type]func(raw any) (any, error), typ reflect.Type) (func(raw any) (any, error), bool) {
+	dec, ok := decoders[typ]
+	if !ok {
+		dec, ok = defaultTypeDecoders[typ]
+	}
+	if ok {
+		return func(raw any) (any, error) {
+			return decodeType(dec, raw, typ)
+		}, true
+	}
+
+	ptrType := reflect.PointerTo(typ)
+	dec, ok = decoders[ptrType]
+	if !ok {
+		dec, ok = defaultTypeDecoders[ptrType]
+	}
+	if !ok {
+		return nil, false
+	}
+	return func(raw any) (any, error) {
+		v, err := decodeType(dec, raw, ptrType)
+		if err != nil {
+			return nil, err
+		}
+		ptr := reflect.ValueOf(v)
+		if ptr.IsNil() {
+			return reflect.Zero(typ).Interface(), nil
+		}
+		return ptr.Elem().Interface(), nil
+	}, true
+}
+
+// hasTypeDecoder reports whether there is a decoder for a type, a pointer to it or a type it points to.
+// time.Time is decoded with a layout tag, see parseTime. Optional is decoded as its value.
+func hasTypeDecoder(decoders map[reflect.Type]func(raw any) (any, error), typ reflect.Type) bool {
+	for ; typ.Kind() == reflect.Ptr; typ = typ.Elem() {
+		if _, ok := typeDecoder(decoders, typ); ok {
+			return true
+		}
+	}
+	_, ok := typeDecoder(decoders, typ)
+	return ok || typ == timeType || isOptional(typ)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// parseTime parses time with a layout from `layout` tag: Go time layout, unix or unixms.
+// RFC 3339 is used by default, time values from file decoders are used as is.
+func parseTime(value any, layout string) (time.Time, error) {
+	var s string
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v == nil {
+			return time.Time{}, nil
+		}
+		return *v, nil
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		s = fmt.Sprint(value)
+	}
+
+	switch layout {
+	case "":
+		return time.Parse(time.RFC3339, s)
+	case "unix", "unixms":
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if layout == "unix" {
+			return time.Unix(n, 0), nil
+		}
+		return time.UnixMilli(n), nil
+	default:
+		return time.Parse(layout, s)
+	}
+}
+
+// decodeType decodes a raw value with a type decoder and checks the result type.
+func decodeType(dec func(raw any) (any, error), raw any, typ reflect.Type) (any, error) {
+	v, err := dec(raw)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return reflect.Zero(typ).Interface(), nil
+	}
+	if got := reflect.TypeOf(v); !got.AssignableTo(typ) {
+		return nil, fmt.Errorf("type decoder for %v returned %v", typ, got)
+	}
+	return v, nil
+}
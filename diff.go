@@ -0,0 +1,150 @@
+package aconfig
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+)
+
+// SecretMask replaces values of secret fields in Change.
+const SecretMask = "***"
+
+// Change of a single config field.
+type Change struct {
+	// Path of the field (like DB.Port).
+	Path string
+	// Old and New values of the field, pointers are dereferenced.
+	// Values of fields with `secret:"true"` tag (or inside such struct) are SecretMask.
+	Old, New any
+	// Secret reports whether the field is secret.
+	Secret bool
+}
+
+// Diff returns changed fields between 2 configs of the same type.
+// Configs can be structs or pointers to structs. Fields are walked like in Loader.WalkFields.
+func Diff(old, new any) []Change {
+	oldValue, newValue := structValue(old), structValue(new)
+	if oldValue.Type() != newValue.Type() {
+		panic(fmt.Sprintf("aconfig: cannot diff %T and %T", old, new))
+	}
+
+	return diffValues(oldValue, newValue, func(*fieldData) bool { return true })
+}
+
+// diffValues returns changes of fields accepted by filter.
+func diffValues(oldValue, newValue reflect.Value, filter func(fd *fieldData) bool) []Change {
+	oldFields := collectValueFields(oldValue)
+	newFields := collectValueFields(newValue)
+
+	var changes []Change
+	for i, oldField := range oldFields {
+		if !filter(oldField) {
+			continue
+		}
+		newField := newFields[i]
+		oldV, newV := derefValue(oldField.value), derefValue(newField.value)
+		if reflect.DeepEqual(oldV, newV) {
+			continue
+		}
+
+		change := Change{
+			Path:   oldField.name,
+			Old:    oldV,
+			New:    newV,
+			Secret: isSecret(oldField),
+		}
+		if change.Secret {
+			change.Old, change.New = SecretMask, SecretMask
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func structValue(x any) reflect.Value {
+	if x == nil {
+		panic("aconfig: config cannot be nil")
+	}
+	value := reflect.ValueOf(x)
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		panic("aconfig: config must be a struct")
+	}
+	return value
+}
+
+func collectValueFields(value reflect.Value) []*fieldData {
+	var fields []*fieldData
+	walkValueFields(value, nil, func(fd *fieldData) {
+		fields = append(fields, fd)
+	})
+	return fields
+}
+
+// walkValueFields walks fields like getFields but doesn't modify the value.
+// Nil pointers to structs are walked as zero structs.
+func walkValueFields(valueObject reflect.Value, parent *fieldData, fn func(fd *fieldData)) {
+	typeObject := valueObject.Type()
+
+	for i := 0; i < typeObject.NumField(); i++ {
+		field := typeObject.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		value := valueObject.Field(i)
+
+		fd := &fieldData{
+			name:   makeName(field.Name, parent),
+			parent: parent,
+			field:  field,
+			value:  value,
+		}
+
+		typ := field.Type
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct || isTextUnmarshaler(typ) || hasTypeDecoder(nil, typ) {
+			fn(fd)
+			continue
+		}
+
+		if value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				value = reflect.Zero(typ)
+			} else {
+				value = value.Elem()
+			}
+		}
+		subFieldParent := fd
+		if field.Anonymous {
+			subFieldParent = parent
+		}
+		walkValueFields(value, subFieldParent, fn)
+	}
+}
+
+func isTextUnmarshaler(typ reflect.Type) bool {
+	return reflect.PointerTo(typ).Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem())
+}
+
+func derefValue(value reflect.Value) any {
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	return value.Interface()
+}
+
+func isSecret(fd *fieldData) bool {
+	for f := fd; f != nil; f = f.parent {
+		if f.field.Tag.Get("secret") == "true" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package aconfig
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	type DB struct {
+		Host     string
+		Password string `secret:"true"`
+	}
+	type Embedded struct {
+		Level string
+	}
+	type TestConfig struct {
+		Port    int
+		Timeout *time.Duration
+		Tags    []string
+		Started time.Time
+		Limit   Optional[int]
+		DB      DB
+		Auth    *struct {
+			Token string
+		} `secret:"true"`
+		Embedded
+	}
+
+	timeout := time.Second
+	old := TestConfig{
+		Port: 80,
+		Tags: []string{"a"},
+		DB:   DB{Host: "localhost", Password: "pass1"},
+	}
+	new := TestConfig{
+		Port:    80,
+		Timeout: &timeout,
+		Tags:    []string{"a", "b"},
+		Started: time.Unix(1, 0),
+		Limit:   NewOptional(0),
+		DB:      DB{Host: "localhost", Password: "pass2"},
+		Auth: &struct {
+			Token string
+		}{Token: "token"},
+		Embedded: Embedded{Level: "debug"},
+	}
+
+	want := []Change{
+		{Path: "Timeout", Old: nil, New: time.Second},
+		{Path: "Tags", Old: []string{"a"}, New: []string{"a", "b"}},
+		{Path: "Started", Old: time.Time{}, New: time.Unix(1, 0)},
+		{Path: "Limit", Old: Optional[int]{}, New: NewOptional(0)},
+		{Path: "DB.Password", Old: SecretMask, New: SecretMask, Secret: true},
+		{Path: "Auth.Token", Old: SecretMask, New: SecretMask, Secret: true},
+		{Path: "Level", Old: "", New: "debug"},
+	}
+	mustEqual(t, Diff(old, &new), want)
+	mustEqual(t, len(Diff(&new, &new)), 0)
+}
+
+func TestDiffBadType(t *testing.T) {
+	defer func() {
+		if err := recover(); err == nil {
+			t.Fatal("must panic")
+		}
+	}()
+
+	Diff(struct{ A int }{}, struct{ B int }{})
+}
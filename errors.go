@@ -0,0 +1,167 @@
+package aconfig
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Errors reported by Loader. Use errors.Is to check them.
+var (
+	ErrRequired     = errors.New("required but not set")
+	ErrUnknownField = errors.New("unknown field")
+	ErrUnknownEnv   = errors.New("unknown environment var")
+	ErrUnknownFlag  = errors.New("unknown flag")
+	ErrDuplicate    = errors.New("duplicated")
+	ErrBadValue     = errors.New("bad value")
+	ErrBadTag       = errors.New("bad tag")
+
+	// ErrRestartRequired is returned by Value.Reload for changed fields with `reload:"false"` tag.
+	ErrRestartRequired = errors.New("changed but requires restart")
+)
+
+// LoadError is returned by Loader.Load when one or more fields cannot be loaded.
+// Every problem found during loading is reported, not only the first one.
+type LoadError struct {
+	Errors []*FieldError
+}
+
+// Error implements error interface.
+func (e *LoadError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%d errors: ", len(e.Errors))
+	for i, err := range e.Errors {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+// Unwrap returns every FieldError, so errors.Is and errors.As can be used.
+func (e *LoadError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// FieldError describes a problem with a single field or a key in a source.
+type FieldError struct {
+	// Path of the field (like DB.Port) or a key from the source for unknown keys.
+	Path string
+	// Source where the problem was found: default, file "config.json", dir "/etc/app", env, flag.
+	// Empty when the problem is not related to a source (like a required field).
+	Source string
+	// Value that caused the problem, if any.
+	Value any
+	// Err is one of the Err* errors, possibly wrapped with details.
+	Err error
+}
+
+// Error implements error interface.
+func (e *FieldError) Error() string {
+	if e.Source == "" {
+		return fmt.Sprintf("%s: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %v", e.Source, e.Path, e.Err)
+}
+
+// Unwrap returns underlying error.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// unknownError returns *LoadError for every key with a prefix in values, nil if there are no such keys.
+// The closest known name is suggested for every unknown key.
+func unknownError(source string, values map[string]any, prefix string, err error, param string, known []string) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+
+	errs := make([]*FieldError, len(names))
+	for i, name := range names {
+		var details string
+		if suggestion := suggestName(name, known); suggestion != "" {
+			details += ", did you mean " + suggestion + "?"
+		}
+		if param != "" {
+			details += " (see " + param + " config param)"
+		}
+		errUnknown := fmt.Errorf("%w%s", err, details)
+
+		errs[i] = &FieldError{
+			Path:   name,
+			Source: source,
+			Value:  values[name],
+			Err:    errUnknown,
+		}
+	}
+	return &LoadError{Errors: errs}
+}
+
+func badValueError(value any, err error) error {
+	return fmt.Errorf("%w %q: %w", ErrBadValue, fmt.Sprint(value), err)
+}
+
+func fileSource(file string) string {
+	return fmt.Sprintf("file %q", file)
+}
+
+// filePosSource returns file:line:col source for a key if its position is known.
+func filePosSource(file string, positions map[string]Position, key string) string {
+	pos, ok := positions[key]
+	// servers[1].port has a position of servers key.
+	for !ok {
+		i := strings.LastIndexAny(key, ".[")
+		if i <= 0 {
+			return fileSource(file)
+		}
+		key = key[:i]
+		pos, ok = positions[key]
+	}
+	return fmt.Sprintf("%s:%d:%d", file, pos.Line, pos.Column)
+}
+
+// withFileSource sets file source for every key error in *LoadError.
+func withFileSource(err error, file string, positions map[string]Position) error {
+	var loadErr *LoadError
+	if errors.As(err, &loadErr) {
+		for _, fe := range loadErr.Errors {
+			fe.Source = filePosSource(file, positions, fe.Path)
+		}
+	}
+	return err
+}
+
+// subPositions returns positions for keys with a prefix, the prefix is removed.
+func subPositions(positions map[string]Position, prefix string) map[string]Position {
+	if positions == nil {
+		return nil
+	}
+	res := make(map[string]Position)
+	for key, pos := range positions {
+		if strings.HasPrefix(key, prefix) {
+			res[strings.TrimPrefix(key, prefix)] = pos
+		}
+	}
+	return res
+}
+
+func dirSource(dir string) string {
+	return fmt.Sprintf("dir %q", dir)
+}
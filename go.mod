@@ -1,5 +1,5 @@
 module github.com/cristalhq/aconfig
 
-go 1.18
+go 1.21
 
 require github.com/mitchellh/mapstructure v1.5.0
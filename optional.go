@@ -0,0 +1,76 @@
+package aconfig
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Optional is a value that is either set by a source or not set at all.
+// Unlike a plain field it tells 'timeout: 0' in a file from a missing timeout.
+//
+// Optional[T] is loaded like a field of type T, all the tags of the field are applied to T.
+// Non-empty `default` tag also sets it.
+type Optional[T any] struct {
+	value T
+	isSet bool
+}
+
+// NewOptional returns a set Optional with a given value.
+func NewOptional[T any](value T) Optional[T] {
+	return Optional[T]{value: value, isSet: true}
+}
+
+// Get returns the value, zero value if it's not set.
+func (o Optional[T]) Get() T {
+	return o.value
+}
+
+// GetOr returns the value if it's set, otherwise def.
+func (o Optional[T]) GetOr(def T) T {
+	if !o.isSet {
+		return def
+	}
+	return o.value
+}
+
+// IsSet reports whether the value was set.
+func (o Optional[T]) IsSet() bool {
+	return o.isSet
+}
+
+// String implements fmt.Stringer.
+func (o Optional[T]) String() string {
+	if !o.isSet {
+		return "<unset>"
+	}
+	return fmt.Sprint(o.value)
+}
+
+func (o *Optional[T]) valuePtr() any {
+	return &o.value
+}
+
+func (o *Optional[T]) markSet() {
+	o.isSet = true
+}
+
+// optionalValue is implemented by *Optional[T].
+type optionalValue interface {
+	valuePtr() any
+	markSet()
+}
+
+var optionalValueType = reflect.TypeOf((*optionalValue)(nil)).Elem()
+
+func isOptional(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Struct && reflect.PointerTo(typ).Implements(optionalValueType)
+}
+
+// asOptional returns an addressable value as optionalValue.
+func asOptional(value reflect.Value) (optionalValue, bool) {
+	if !value.CanAddr() || !isOptional(value.Type()) {
+		return nil, false
+	}
+	opt, ok := value.Addr().Interface().(optionalValue)
+	return opt, ok
+}
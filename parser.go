@@ -2,9 +2,11 @@ package aconfig
 
 import (
 	"encoding"
+	"errors"
 	"flag"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 
 type structParser struct {
 	cfg       Config
+	profile   string
 	fields    map[string]any
 	flagSet   *flag.FlagSet
 	envNames  map[string]struct{}
@@ -34,10 +37,13 @@ type parsedField struct {
 	value        any
 	defaultValue any
 	parent       *parsedField
+	typ          reflect.Type
 	childs       map[string]any
 	tags         map[string]string
 	hasChilds    bool
 	isRequired   bool
+	isSecret     bool
+	source       string // where the value is set from, for decode errors.
 }
 
 func (pf *parsedField) String() string {
@@ -50,7 +56,12 @@ func (pf *parsedField) String() string {
 func (sp *structParser) newParseField(parent *parsedField, field reflect.StructField) (*parsedField, error) {
 	requiredTag := field.Tag.Get("required")
 	if requiredTag != "" && requiredTag != "true" {
-		panic(fmt.Sprintf("aconfig: value for 'required' tag can be only 'true' got: %q", requiredTag))
+		return nil, &FieldError{
+			Path:   field.Name,
+			Source: "tag",
+			Value:  requiredTag,
+			Err:    fmt.Errorf("%w: 'required' can be only 'true', got %q", ErrBadTag, requiredTag),
+		}
 	}
 
 	name := field.Tag.Get("name")
@@ -95,19 +106,29 @@ func (sp *structParser) newParseField(parent *parsedField, field reflect.StructF
 		name:     name,
 		namefull: parentName + name,
 		parent:   parent,
+		typ:      field.Type,
 		tags: map[string]string{
 			"usage":     field.Tag.Get("usage"),
+			"layout":    field.Tag.Get("layout"),
+			"unit":      field.Tag.Get("unit"),
+			"from":      field.Tag.Get("from"),
+			"encoding":  field.Tag.Get("encoding"),
 			"env_name":  env,
 			"env_full":  sp.cfg.EnvPrefix + parentEnv + env,
 			"flag_name": flag,
 			"flag_full": sp.cfg.FlagPrefix + parentFlag + flag,
 		},
 		isRequired: requiredTag == "true",
+		isSecret:   field.Tag.Get("secret") == "true" || (parent != nil && parent.isSecret),
 	}
 
 	if !sp.cfg.SkipDefaults {
 		// TODO: must be typed?
-		pfield.defaultValue = field.Tag.Get("default")
+		pfield.defaultValue = defaultTag(field.Tag, sp.profile)
+	}
+
+	if key := field.Tag.Get("discriminator"); key != "" {
+		pfield.tags["discriminator"] = key
 	}
 
 	if env == "-" {
@@ -127,7 +148,12 @@ func (sp *structParser) newParseField(parent *parsedField, field reflect.StructF
 	if !sp.cfg.AllowDuplicates {
 		name := pfield.tags["env_full"]
 		if _, ok := sp.envNames[name]; ok && name != "" {
-			return nil, fmt.Errorf("field %q is duplicated", name)
+			return nil, &FieldError{
+				Path:   strings.ReplaceAll(pfield.namefull, "|", "."),
+				Source: "env",
+				Value:  name,
+				Err:    fmt.Errorf("%w name %q", ErrDuplicate, name),
+			}
 		}
 		sp.envNames[name] = struct{}{}
 	}
@@ -136,11 +162,20 @@ func (sp *structParser) newParseField(parent *parsedField, field reflect.StructF
 		flagName := pfield.tags["flag_full"]
 		if flagName != "" {
 			if _, ok := sp.flagNames[flagName]; ok && !sp.cfg.AllowDuplicates {
-				return nil, fmt.Errorf("duplicate flag %q", flagName)
+				return nil, &FieldError{
+					Path:   strings.ReplaceAll(pfield.namefull, "|", "."),
+					Source: "flag",
+					Value:  flagName,
+					Err:    fmt.Errorf("%w flag %q", ErrDuplicate, flagName),
+				}
 			}
 			sp.flagNames[flagName] = struct{}{}
-			// TODO: must be typed
-			sp.flagSet.String(flagName, field.Tag.Get("default"), field.Tag.Get("usage"))
+			if hasVariants(field.Type) {
+				registerVariantFlags(sp.flagSet, sp.cfg, flagName, field.Type, field.Tag)
+			} else {
+				// TODO: must be typed
+				sp.flagSet.String(flagName, field.Tag.Get("default"), withDefaultVariants(field.Tag.Get("usage"), field.Tag))
+			}
 		}
 	}
 
@@ -186,7 +221,7 @@ func (sp *structParser) parseStructHelper(parent *parsedField, structValue refle
 			continue
 		}
 
-		defaultTagValue := field.Tag.Get("default")
+		defaultTagValue := defaultTag(field.Tag, sp.profile)
 		pfield, err := sp.newParseField(parent, field)
 		if err != nil {
 			return nil, err
@@ -198,6 +233,7 @@ func (sp *structParser) parseStructHelper(parent *parsedField, structValue refle
 		// 	pfield.defaultValue = nil
 		// }
 
+		dstValue := fieldValue
 		if fieldType.Kind() == reflect.Pointer {
 			fieldValue = fieldValue.Elem()
 			fieldValue = reflect.New(fieldType)
@@ -220,12 +256,23 @@ func (sp *structParser) parseStructHelper(parent *parsedField, structValue refle
 		// 	continue
 		// }
 
-		switch fieldType.Kind() {
+		kind := fieldType.Kind()
+		if hasTypeDecoder(sp.cfg.TypeDecoders, field.Type) {
+			kind = reflect.Invalid // decoded as a single value.
+		}
+
+		switch kind {
 		// case reflect.Array:
 		// TODO: same as slice + check len?
 
 		case reflect.Interface:
-			// TODO: just assign?
+			if hasVariants(field.Type) {
+				vv := newVariantValues(strings.ReplaceAll(pfield.namefull, "|", "."), field.Tag.Get("discriminator"))
+				if !sp.cfg.SkipDefaults {
+					vv.defaultName = defaultTagValue
+				}
+				value = vv
+			}
 
 		case reflect.Struct:
 			pfield.hasChilds = true
@@ -247,7 +294,17 @@ func (sp *structParser) parseStructHelper(parent *parsedField, structValue refle
 			value = values
 
 		case reflect.Slice, reflect.Array:
-			if isPrimitive(field.Type.Elem()) {
+			if fieldType.Kind() == reflect.Array {
+				value = nil
+				if defaultTagValue != "" {
+					items, err := listItems(defaultTagValue)
+					if err != nil {
+						return nil, fmt.Errorf("incorrect default tag value for array: %w", err)
+					}
+					value = items
+				}
+				pfield.hasChilds = !isPrimitive(field.Type.Elem())
+			} else if isPrimitive(field.Type.Elem()) {
 				// byte-slice case
 				if field.Type.Elem().Kind() == reflect.Uint8 {
 					value = []byte(defaultTagValue)
@@ -256,8 +313,10 @@ func (sp *structParser) parseStructHelper(parent *parsedField, structValue refle
 					if defaultTagValue != "" && !strings.Contains(defaultTagValue, ",") {
 						return nil, fmt.Errorf("incorrect default tag value for slice/array: %v", defaultTagValue)
 					}
-					for _, val := range strings.Split(defaultTagValue, ",") {
-						values = append(values, val)
+					if defaultTagValue != "" {
+						for _, val := range strings.Split(defaultTagValue, ",") {
+							values = append(values, val)
+						}
 					}
 					value = values
 				}
@@ -265,6 +324,14 @@ func (sp *structParser) parseStructHelper(parent *parsedField, structValue refle
 				pfield.hasChilds = true
 				// TODO: if value is struct - parse
 				// value = parseSlice(fieldValue, map[string]any{})
+
+				if strings.HasPrefix(strings.TrimSpace(defaultTagValue), "[") {
+					items, err := listItems(defaultTagValue)
+					if err != nil {
+						return nil, fmt.Errorf("incorrect default tag value for slice: %w", err)
+					}
+					value = items
+				}
 			}
 
 			// if !sp.cfg.SkipDefaults {
@@ -297,15 +364,15 @@ func (sp *structParser) parseStructHelper(parent *parsedField, structValue refle
 
 		default:
 			// TODO: do not set pointer
-			if fieldType.Kind() == reflect.Pointer && defaultTagValue == "" {
+			if (fieldType.Kind() == reflect.Pointer || isOptional(fieldType)) && defaultTagValue == "" {
 				// skip
 				value = nil
 			} else {
 				// TODO: when WeaklyTypedInput will be false use decodePrimitive(...)
 				if !sp.cfg.SkipDefaults {
 					value = defaultTagValue
-					if fieldType == reflect.TypeOf(time.Second) {
-						val, err := time.ParseDuration(defaultTagValue)
+					if fieldType == reflect.TypeOf(time.Second) && defaultTagValue != "" {
+						val, err := parseDuration(defaultTagValue)
 						if err != nil {
 							return nil, err
 						}
@@ -315,13 +382,23 @@ func (sp *structParser) parseStructHelper(parent *parsedField, structValue refle
 			}
 		}
 
-		// we should not overwrite struct because there are childs
-		if sp.cfg.SkipDefaults && fieldType.Kind() != reflect.Struct {
+		// we should not overwrite struct because there are childs, variants are decoded on apply.
+		if _, ok := value.(*variantValues); ok {
+			pfield.value = value
+		} else if sp.cfg.SkipDefaults && fieldType.Kind() != reflect.Struct {
 			pfield.value = fieldValue.Interface()
+		} else if defaultTagValue == "" && !pfield.hasChilds && !dstValue.IsZero() {
+			// keep a value set in the destination before loading.
+			pfield.value = dstValue.Interface()
 		} else {
 			pfield.value = value
 		}
 
+		if !sp.cfg.SkipDefaults && defaultTagValue != "" && fieldType.Kind() != reflect.Struct {
+			pfield.source = "default"
+			sp.logFieldSet(pfield, "default", defaultTagValue)
+		}
+
 		// fmt.Printf("def: %v %T '%+v'\n", fieldType.String(), value, value)
 		res[pfield.name] = pfield
 	}
@@ -330,54 +407,550 @@ func (sp *structParser) parseStructHelper(parent *parsedField, structValue refle
 
 var fieldType = reflect.TypeOf(&parsedField{})
 
-var hook = mapstructure.DecodeHookFuncType(func(from, to reflect.Type, data any) (any, error) {
+func (sp *structParser) hook(from, to reflect.Type, data any) (any, error) {
+	value := data
+	if field, ok := data.(*parsedField); ok {
+		value = field.value
+	}
+	if err := checkNullItems(value, to); err != nil {
+		return nil, err
+	}
+
 	if from != fieldType {
 		// fmt.Printf("hook: got %T (%+v) when %s\n", i, i, to.String())
+		if hasVariants(to) && (from.Kind() == reflect.Map || from.Kind() == reflect.String) {
+			return sp.decodeVariant(to, "", data)
+		}
+		if dec, ok := typeDecoder(sp.cfg.TypeDecoders, to); ok && from != to {
+			return dec(data)
+		}
+		if to == timeType && from != to {
+			return parseTime(data, "")
+		}
+		if isOptional(to) && from != to {
+			return sp.decodeOptional(&parsedField{value: data, tags: map[string]string{}}, to)
+		}
 		return data, nil
 	}
 	field := data.(*parsedField)
 
+	if content, ok, err := readContent(&fsOrOS{sp.cfg.FileSystem}, field.tags["from"], field.tags["encoding"], field.value); ok {
+		if err != nil {
+			return nil, err
+		}
+		value, err := contentValue(content, field.tags["from"], to)
+		if err != nil || reflect.TypeOf(value) == to {
+			return value, err
+		}
+		contentField := *field
+		contentField.value = value
+		field = &contentField
+	}
+
+	if field.value == nil {
+		// null from a file, mapstructure sets nil pointers only for untyped nil.
+		if to.Kind() == reflect.Ptr {
+			return nil, nil
+		}
+		return reflect.Zero(to).Interface(), nil
+	}
+	if vv, ok := field.value.(*variantValues); ok {
+		if !vv.value.IsValid() {
+			return nil, nil
+		}
+		return vv.value.Interface(), nil
+	}
+	if hasVariants(to) {
+		return sp.decodeVariant(to, field.tags["discriminator"], field.value)
+	}
+	if isOptional(to) {
+		return sp.decodeOptional(field, to)
+	}
+	if dec, ok := typeDecoder(sp.cfg.TypeDecoders, to); ok {
+		if field.value == nil || field.value == "" {
+			return reflect.Zero(to).Interface(), nil
+		}
+		if reflect.TypeOf(field.value) == to {
+			return field.value, nil
+		}
+		return dec(field.value)
+	}
+	if to.Kind() == reflect.Array && field.value != nil && field.value != "" && reflect.TypeOf(field.value) != to {
+		items, err := listItems(field.value)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) != to.Len() {
+			return nil, fmt.Errorf("field %s: want %d items, got %d", field.name, to.Len(), len(items))
+		}
+		return items, nil
+	}
+	if isTimeField(to) {
+		return parseTimeField(field.value, field.tags["layout"], to)
+	}
+	if str, ok := field.value.(string); ok && field.tags["unit"] == "bytes" && str != "" {
+		size, err := parseByteSize(str)
+		if err != nil || !isNumeric(to) {
+			return size, err
+		}
+		return convertPrimitive(size, to)
+	}
+	if isNumeric(to) && !isTextUnmarshaler(to) && field.value != nil && field.value != "" {
+		return convertPrimitive(field.value, to)
+	}
+	if str, ok := field.value.(string); ok && to.Kind() == reflect.Slice && hasTypeDecoder(sp.cfg.TypeDecoders, to.Elem()) {
+		if str == "" {
+			return []string{}, nil
+		}
+		parts := strings.Split(str, ",")
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		return parts, nil
+	}
+
 	ifaceTo := reflect.New(to).Interface()
 	if unmarshaller, ok := ifaceTo.(encoding.TextUnmarshaler); ok {
 		// TODO: only string can be here?
-		b := []byte(field.value.(string))
-		err := unmarshaller.UnmarshalText(b)
+		str, ok := field.value.(string)
+		if !ok {
+			return nil, fmt.Errorf("field %s: want a string, got %T", field.name, field.value)
+		}
+		err := unmarshaller.UnmarshalText([]byte(str))
 		return unmarshaller, err
 	}
 	// fmt.Printf("hook: when %s do '%+v' // %+v\n\n", to.String(), field.value, field)
 	return field.value, nil
-})
+}
+
+// arrayHook decodes array fields of a struct with elements that aren't comparable ([2][]int),
+// mapstructure compares arrays with their zero value and panics on such types.
+// Decoded fields are removed from the returned values, so mapstructure skips them.
+func (sp *structParser) arrayHook(from, to reflect.Value) (any, error) {
+	if !from.IsValid() {
+		return nil, nil
+	}
+	data := from.Interface()
+	values, ok := data.(map[string]any)
+	if !ok || to.Kind() != reflect.Struct || !to.CanSet() {
+		return data, nil
+	}
+
+	var rest map[string]any
+	for i := 0; i < to.NumField(); i++ {
+		field := to.Type().Field(i)
+		if field.Type.Kind() != reflect.Array || field.Type.Comparable() || !field.IsExported() {
+			continue
+		}
+		key, ok := matchKey(values, field.Name)
+		if !ok {
+			continue
+		}
+		if err := sp.decodeArray(values[key], to.Field(i)); err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		if rest == nil {
+			rest = make(map[string]any, len(values))
+			for k, v := range values {
+				rest[k] = v
+			}
+		}
+		delete(rest, key)
+	}
+	if rest == nil {
+		return data, nil
+	}
+	return rest, nil
+}
+
+// layoutHook parses times with `layout` tag in objects decoded into structs without parsed fields,
+// like items of a slice of structs, other values keep tags of their fields.
+func (sp *structParser) layoutHook(from, to reflect.Value) (any, error) {
+	if !from.IsValid() {
+		return nil, nil
+	}
+	data := from.Interface()
+	values, ok := data.(map[string]any)
+	if !ok || to.Kind() != reflect.Struct {
+		return data, nil
+	}
+
+	var res map[string]any
+	for i := 0; i < to.NumField(); i++ {
+		field := to.Type().Field(i)
+		layout := field.Tag.Get("layout")
+		if layout == "" || !isTimeField(field.Type) || !field.IsExported() {
+			continue
+		}
+		key, ok := matchKey(values, field.Name)
+		if !ok {
+			continue
+		}
+		if _, ok := values[key].(*parsedField); ok {
+			continue // tags are applied by hook.
+		}
+
+		value, err := parseTimeField(values[key], layout, field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		if res == nil {
+			res = make(map[string]any, len(values))
+			for k, v := range values {
+				res[k] = v
+			}
+		}
+		res[key] = value
+	}
+	if res == nil {
+		return data, nil
+	}
+	return res, nil
+}
+
+// decodeArray decodes a list into a slice of items, checks the length and copies them to arr.
+func (sp *structParser) decodeArray(value any, arr reflect.Value) error {
+	if field, ok := value.(*parsedField); ok {
+		value = field.value
+	}
+	switch {
+	case value == nil || value == "":
+		return nil
+	case reflect.TypeOf(value) == arr.Type():
+		arr.Set(reflect.ValueOf(value))
+		return nil
+	}
+
+	items, err := listItems(value)
+	if err != nil {
+		return err
+	}
+	if len(items) != arr.Len() {
+		return fmt.Errorf("want %d items, got %d", arr.Len(), len(items))
+	}
+
+	elemType := arr.Type().Elem()
+	slice := reflect.MakeSlice(reflect.SliceOf(elemType), len(items), len(items))
+	for i, item := range items {
+		var err error
+		if elemType.Kind() == reflect.Array && !elemType.Comparable() {
+			err = sp.decodeArray(item, slice.Index(i))
+		} else {
+			err = sp.newDecoder(slice.Index(i).Addr().Interface()).Decode(item)
+		}
+		if err != nil {
+			return fmt.Errorf("incorrect array item %v: %w", item, err)
+		}
+	}
+	reflect.Copy(arr, slice)
+	return nil
+}
+
+// matchKey returns a key of values for a field name, matched like in mapstructure.
+func matchKey(values map[string]any, name string) (string, bool) {
+	if _, ok := values[name]; ok {
+		return name, true
+	}
+	for key := range values {
+		if strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// checkNullItems returns an error for null items of a list decoded into a slice of primitives,
+// mapstructure silently keeps zero values for them.
+func checkNullItems(value any, to reflect.Type) error {
+	items, ok := value.([]any)
+	if !ok || to.Kind() != reflect.Slice || !isPrimitive(to.Elem()) {
+		return nil
+	}
+	for i, item := range items {
+		if item == nil {
+			return fmt.Errorf("want a value, got null at index %d", i)
+		}
+	}
+	return nil
+}
+
+// isListValue reports whether a value is a list from a file or a non-empty string from env, flags and tags.
+func isListValue(value any) bool {
+	switch value := value.(type) {
+	case []any:
+		return true
+	case string:
+		return value != ""
+	}
+	return false
+}
+
+// decodeOptional decodes a field value into Optional value, nil value is not set.
+func (sp *structParser) decodeOptional(field *parsedField, to reflect.Type) (any, error) {
+	if field.value == nil {
+		return reflect.Zero(to).Interface(), nil
+	}
+	if reflect.TypeOf(field.value) == to {
+		return field.value, nil
+	}
+
+	ptr := reflect.New(to)
+	opt := ptr.Interface().(optionalValue)
+	inner := *field
+	if err := sp.newDecoder(opt.valuePtr()).Decode(&inner); err != nil {
+		return nil, err
+	}
+	opt.markSet()
+	return ptr.Elem().Interface(), nil
+}
+
+// decodeVariant decodes a slice item or a value set as a whole into a variant.
+func (sp *structParser) decodeVariant(to reflect.Type, key string, value any) (any, error) {
+	v, err := decodeVariant(sp.cfg, sp.profile, to, key, value)
+	if err != nil || !v.IsValid() {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// decodeVariantSlice decodes a list from a file or a JSON string from env and flags into a slice of variants.
+func (sp *structParser) decodeVariantSlice(to reflect.Type, key string, value any) (any, error) {
+	items, err := listItems(value)
+	if err != nil {
+		return nil, err
+	}
+	slice := reflect.MakeSlice(to, len(items), len(items))
+	for i, item := range items {
+		v, err := sp.decodeVariant(to.Elem(), key, item)
+		if err != nil {
+			return nil, fmt.Errorf("incorrect slice item %v: %w", item, err)
+		}
+		if v != nil {
+			slice.Index(i).Set(reflect.ValueOf(v))
+		}
+	}
+	return slice.Interface(), nil
+}
+
+// isTimeField reports whether a type is time, a pointer to time or a slice of times.
+func isTimeField(typ reflect.Type) bool {
+	return typ == timeType || typ == reflect.PointerTo(timeType) || (typ.Kind() == reflect.Slice && typ.Elem() == timeType)
+}
+
+// parseTimeField parses time, a pointer to time or a slice of times with a layout.
+func parseTimeField(value any, layout string, to reflect.Type) (any, error) {
+	if value == nil || value == "" {
+		return reflect.Zero(to).Interface(), nil
+	}
+
+	if to.Kind() == reflect.Slice {
+		var values []any
+		switch v := value.(type) {
+		case string:
+			for _, part := range strings.Split(v, ",") {
+				values = append(values, strings.TrimSpace(part))
+			}
+		case []any:
+			values = v
+		default:
+			return nil, fmt.Errorf("want a list or a string, got %T", value)
+		}
+
+		res := make([]time.Time, len(values))
+		for i, v := range values {
+			t, err := parseTime(v, layout)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = t
+		}
+		return res, nil
+	}
+
+	t, err := parseTime(value, layout)
+	if err != nil {
+		return nil, err
+	}
+	if to.Kind() == reflect.Ptr {
+		return &t, nil
+	}
+	return t, nil
+}
 
 func (sp *structParser) apply(x any) error {
+	errs := sp.decodeVariants(sp.fields, nil)
+	err := sp.newDecoder(x).Decode(sp.fields)
+
+	var decodeErr *mapstructure.Error
+	switch {
+	case errors.As(err, &decodeErr):
+		for _, msg := range decodeErr.Errors {
+			errs = append(errs, sp.decodeError(msg))
+		}
+	case err != nil:
+		return fmt.Errorf("decode: %w", err)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Path < errs[j].Path
+	})
+	return &LoadError{Errors: errs}
+}
+
+// decodeVariants decodes variant fields before apply, so their errors are reported as is
+// and not as mapstructure strings.
+func (sp *structParser) decodeVariants(fields map[string]any, errs []*FieldError) []*FieldError {
+	for _, name := range sortedKeys(fields) {
+		pfield, ok := fields[name].(*parsedField)
+		if !ok {
+			continue
+		}
+		if childs, ok := pfield.value.(map[string]any); ok && pfield.hasChilds {
+			errs = sp.decodeVariants(childs, errs)
+			continue
+		}
+		if pfield.typ.Kind() == reflect.Slice && hasVariants(pfield.typ.Elem()) && isListValue(pfield.value) {
+			value, err := sp.decodeVariantSlice(pfield.typ, pfield.tags["discriminator"], pfield.value)
+			if err != nil {
+				errs = append(errs, &FieldError{
+					Path:   strings.ReplaceAll(pfield.namefull, "|", "."),
+					Source: pfield.source,
+					Value:  pfield.value,
+					Err:    badValueError(pfield.value, err),
+				})
+				value = nil
+			}
+			pfield.value = value
+			continue
+		}
+
+		vv, ok := pfield.value.(*variantValues)
+		if !ok {
+			continue
+		}
+
+		value, err := vv.decode(sp.cfg, sp.profile, pfield.typ)
+		var fieldErr *FieldError
+		var loadErr *LoadError
+		switch {
+		case err == nil:
+			vv.value = value
+		case errors.As(err, &fieldErr):
+			errs = append(errs, fieldErr)
+		case errors.As(err, &loadErr):
+			errs = append(errs, loadErr.Errors...)
+		default:
+			errs = append(errs, &FieldError{Path: vv.path, Err: err})
+		}
+	}
+	return errs
+}
+
+// decodeError converts an error from mapstructure into *FieldError with a source of the field value.
+// mapstructure reports a field as a quoted path: error decoding 'DB.Port': ...
+func (sp *structParser) decodeError(msg string) *FieldError {
+	var path string
+	if _, rest, ok := strings.Cut(msg, "'"); ok {
+		path, _, _ = strings.Cut(rest, "'")
+	}
+	msg = strings.TrimPrefix(msg, "error decoding '"+path+"': ")
+
+	fe := &FieldError{
+		Path: path,
+		Err:  fmt.Errorf("%w: %s", ErrBadValue, msg),
+	}
+	if pfield, exact := sp.fieldByPath(path); pfield != nil {
+		fe.Source = pfield.source
+		if exact {
+			fe.Value = pfield.value
+			fe.Err = badValueError(pfield.value, errors.New(msg))
+		}
+	}
+	return fe
+}
+
+// fieldByPath returns a field for a path from mapstructure (DB.Port, Servers[1].Port),
+// exact is false for a path inside the field value, like an item of a slice.
+func (sp *structParser) fieldByPath(path string) (pfield *parsedField, exact bool) {
+	fields := sp.fields
+	for _, name := range strings.Split(path, ".") {
+		name, index, hasIndex := strings.Cut(name, "[")
+		pf, ok := fields[name].(*parsedField)
+		if !ok {
+			return pfield, false
+		}
+		pfield = pf
+		if hasIndex && index != "" {
+			return pfield, false
+		}
+		if fields, ok = pfield.value.(map[string]any); !ok {
+			fields = nil
+		}
+	}
+	return pfield, true
+}
+
+func (sp *structParser) newDecoder(result any) *mapstructure.Decoder {
+	hook := mapstructure.ComposeDecodeHookFunc(
+		mapstructure.DecodeHookFuncType(sp.hook),
+		mapstructure.DecodeHookFuncValue(sp.arrayHook),
+		mapstructure.DecodeHookFuncValue(sp.layoutHook),
+	)
 	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
-		Result:           x,
-		DecodeHook:       hook,
-		WeaklyTypedInput: true, // TODO: temp fix?
+		Result:     result,
+		DecodeHook: hook,
+		// values from env, flags and tags are strings, StrictTypes for files is checked in applyLevel.
+		WeaklyTypedInput: true,
 	})
 	if err != nil {
 		panic(fmt.Sprintf("aconfig: BUG with mapstructure.NewDecoder: %v", err))
 	}
-
-	if err := dec.Decode(sp.fields); err != nil {
-		return fmt.Errorf("decode: %w", err)
-	}
-	return nil
+	return dec
 }
 
-func (sp *structParser) applyLevel(tag string, values map[string]any) error {
-	if err := sp.applyLevelHelper2(sp.fields, tag, values); err != nil {
+func (sp *structParser) applyLevel(file, tag string, values map[string]any, positions map[string]Position) error {
+	var errs []*FieldError
+	if err := sp.applyLevelHelper2(sp.fields, file, tag, "", values, positions, &errs); err != nil {
 		return err
 	}
 
 	if !sp.cfg.AllowUnknownFields {
-		for env, value := range values {
-			return fmt.Errorf("unknown field in file %q: %s=%v (see AllowUnknownFields config param)", "file", env, value)
+		var loadErr *LoadError
+		err := unknownError("file", values, "", ErrUnknownField, "AllowUnknownFields", sp.knownNames(tag))
+		if errors.As(withFileSource(err, file, positions), &loadErr) {
+			errs = append(errs, loadErr.Errors...)
 		}
+	} else {
+		logUnknown(sp.cfg.Logger, fileSource(file), values, "")
+	}
+
+	if len(errs) != 0 {
+		sort.SliceStable(errs, func(i, j int) bool {
+			return errs[i].Path < errs[j].Path
+		})
+		return &LoadError{Errors: errs}
 	}
 	return nil
 }
 
-func (sp *structParser) applyLevelHelper2(fields map[string]any, tag string, values map[string]any) error {
+func (sp *structParser) logFieldSet(pfield *parsedField, source string, value any) {
+	path := strings.ReplaceAll(pfield.namefull, "|", ".")
+	logFieldSet(sp.cfg.Logger, path, source, value, pfield.isSecret)
+}
+
+func isStructField(pfield *parsedField) bool {
+	_, ok := pfield.value.(map[string]any)
+	return pfield.hasChilds && ok
+}
+
+// applyLevelHelper2 applies values from a file, errors for values of wrong types
+// in Config.StrictTypes mode are added to errs, prefix is a key of the values.
+// Positions of the keys are kept as field sources for decode errors.
+func (sp *structParser) applyLevelHelper2(fields map[string]any, file, tag, prefix string, values map[string]any, positions map[string]Position, errs *[]*FieldError) error {
 	for _, field := range fields {
 		pfield, ok := field.(*parsedField)
 		if !ok {
@@ -393,6 +966,32 @@ func (sp *structParser) applyLevelHelper2(fields map[string]any, tag string, val
 			continue
 		}
 
+		if vv, ok := pfield.value.(*variantValues); ok {
+			if err := vv.setFile(file, tag, tagValue, value); err != nil {
+				*errs = append(*errs, &FieldError{
+					Path:   prefix + tagValue,
+					Source: filePosSource(file, positions, prefix+tagValue),
+					Value:  value,
+					Err:    err,
+				})
+			}
+			delete(values, tagValue)
+			continue
+		}
+
+		if sp.cfg.StrictTypes && !isStructField(pfield) {
+			if path, key, err := checkStrictType(sp.cfg.TypeDecoders, value, pfield.typ, pfield.tags["unit"]); err != nil {
+				*errs = append(*errs, &FieldError{
+					Path:   strings.ReplaceAll(pfield.namefull, "|", ".") + path,
+					Source: filePosSource(file, positions, prefix+tagValue+key),
+					Value:  value,
+					Err:    err,
+				})
+				delete(values, tagValue)
+				continue
+			}
+		}
+
 		switch value := value.(type) {
 		case map[string]any:
 			if pfield.hasChilds {
@@ -401,15 +1000,40 @@ func (sp *structParser) applyLevelHelper2(fields map[string]any, tag string, val
 					fmt.Printf("ouch %T (%+v)\n", pfield.value, pfield.value)
 					continue
 				}
-				err := sp.applyLevelHelper2(pfieldValue, tag, value)
+				err := sp.applyLevelHelper2(pfieldValue, file, tag, prefix+tagValue+".", value, positions, errs)
 				if err != nil {
 					return err
 				}
+				// keep unknown nested keys to report them with a full name.
+				for k, v := range value {
+					values[tagValue+"."+k] = v
+				}
 			} else {
 				pfield.value = value
+				pfield.source = filePosSource(file, positions, prefix+tagValue)
+				sp.logFieldSet(pfield, fileSource(file), value)
+			}
+		case nil:
+			// null for a struct field keeps its nested values.
+			if !isStructField(pfield) {
+				pfield.value = value
+				pfield.source = filePosSource(file, positions, prefix+tagValue)
+				sp.logFieldSet(pfield, fileSource(file), value)
 			}
 		default:
+			if isStructField(pfield) {
+				*errs = append(*errs, &FieldError{
+					Path:   prefix + tagValue,
+					Source: filePosSource(file, positions, prefix+tagValue),
+					Value:  value,
+					Err:    fmt.Errorf("%w: want an object, got %T", ErrBadValue, value),
+				})
+				delete(values, tagValue)
+				continue
+			}
 			pfield.value = value
+			pfield.source = filePosSource(file, positions, prefix+tagValue)
+			sp.logFieldSet(pfield, fileSource(file), value)
 		}
 
 		delete(values, tagValue)
@@ -457,12 +1081,16 @@ func (sp *structParser) applyLevelHelper(fields map[string]any, tag string, valu
 func (sp *structParser) applyFlat(tag string, values map[string]any) error {
 	allowUnknown := true
 	prefix := ""
+	var errUnknown error
+	var param string
 
 	switch tag {
 	case "env":
 		allowUnknown, prefix = sp.cfg.AllowUnknownEnvs, sp.cfg.EnvPrefix
+		errUnknown, param = ErrUnknownEnv, "AllowUnknownEnvs"
 	case "flag":
 		allowUnknown, prefix = sp.cfg.AllowUnknownFlags, sp.cfg.FlagPrefix
+		errUnknown, param = ErrUnknownFlag, "AllowUnknownFlags"
 	}
 
 	dupls := map[string]struct{}{}
@@ -471,19 +1099,18 @@ func (sp *structParser) applyFlat(tag string, values map[string]any) error {
 		return err
 	}
 
-	if allowUnknown || prefix == "" {
+	if prefix == "" {
 		return nil
 	}
 
 	for name := range dupls {
 		delete(values, name)
 	}
-	for key, value := range values {
-		if strings.HasPrefix(key, prefix) {
-			return fmt.Errorf("unknown %s %s=%v (see AllowUnknownXXX config param)", tag, key, value)
-		}
+	if allowUnknown {
+		logUnknown(sp.cfg.Logger, tag, values, prefix)
+		return nil
 	}
-	return nil
+	return unknownError(tag, values, prefix, errUnknown, param, sp.knownNames(tag))
 }
 
 func (sp *structParser) applyFlatHelper(fields map[string]any, tag string, values map[string]any) error {
@@ -498,18 +1125,33 @@ func (sp *structParser) applyFlatHelper(fields map[string]any, tag string, value
 		if !ok {
 			continue
 		}
+		if vv, ok := pfield.value.(*variantValues); ok {
+			delimiter := sp.cfg.FlagDelimiter
+			if tag == "env" {
+				delimiter = sp.cfg.envDelimiter
+			}
+			vv.takeFlat(tag, tagValue, delimiter, values)
+			continue
+		}
+
 		value, ok := values[tagValue]
 		if !ok {
 			if !pfield.hasChilds {
 				continue
 			}
-			if err := sp.applyFlatHelper(pfield.value.(map[string]any), tag, values); err != nil {
+			childs, ok := pfield.value.(map[string]any)
+			if !ok {
+				continue
+			}
+			if err := sp.applyFlatHelper(childs, tag, values); err != nil {
 				return err
 			}
 			continue
 		}
 
 		pfield.value = value
+		pfield.source = tag
+		sp.logFieldSet(pfield, tag, value)
 		if !sp.cfg.AllowDuplicates {
 			delete(values, tagValue)
 		}
@@ -517,6 +1159,93 @@ func (sp *structParser) applyFlatHelper(fields map[string]any, tag string, value
 	return nil
 }
 
+func (sp *structParser) applyDir(source string, values map[string]any) error {
+	sp.applyDirHelper(sp.fields, source, "", values)
+
+	if !sp.cfg.AllowUnknownFields {
+		return unknownError("dir", values, "", ErrUnknownField, "AllowUnknownFields", sp.knownNames("dir"))
+	}
+	logUnknown(sp.cfg.Logger, source, values, "")
+	return nil
+}
+
+func (sp *structParser) applyDirHelper(fields map[string]any, source, prefix string, values map[string]any) {
+	for _, field := range fields {
+		pfield, ok := field.(*parsedField)
+		if !ok {
+			continue
+		}
+
+		fileName, _, _ := strings.Cut(pfield.tags["json"], ",")
+		fileName = prefix + fileName
+
+		if pfield.hasChilds {
+			if childs, ok := pfield.value.(map[string]any); ok {
+				sp.applyDirHelper(childs, source, fileName+".", values)
+				continue
+			}
+		}
+
+		names := []string{
+			strings.TrimPrefix(pfield.tags["env_full"], sp.cfg.EnvPrefix),
+			fileName,
+		}
+		for _, name := range names {
+			value, ok := values[name]
+			if !ok || name == "" {
+				continue
+			}
+			pfield.value = value
+			pfield.source = source
+			sp.logFieldSet(pfield, source, value)
+			delete(values, name)
+		}
+	}
+}
+
+// knownNames returns all the field names for a given tag, used for suggestions.
+func (sp *structParser) knownNames(tag string) []string {
+	var names []string
+	sp.knownNamesHelper(sp.fields, tag, "", &names)
+	return names
+}
+
+func (sp *structParser) knownNamesHelper(fields map[string]any, tag, prefix string, names *[]string) {
+	for _, field := range fields {
+		pfield, ok := field.(*parsedField)
+		if !ok {
+			continue
+		}
+
+		switch tag {
+		case "env", "flag":
+			if name := pfield.tags[tag+"_full"]; name != "" && !pfield.hasChilds {
+				*names = append(*names, name)
+			}
+		case "dir":
+			if name := strings.TrimPrefix(pfield.tags["env_full"], sp.cfg.EnvPrefix); name != "" && !pfield.hasChilds {
+				*names = append(*names, name)
+			}
+		}
+
+		fileTag := tag
+		if tag == "dir" {
+			fileTag = "json"
+		}
+		name, _, _ := strings.Cut(pfield.tags[fileTag], ",")
+		name = prefix + name
+		if tag != "env" && tag != "flag" {
+			*names = append(*names, name)
+		}
+
+		if pfield.hasChilds {
+			if childs, ok := pfield.value.(map[string]any); ok {
+				sp.knownNamesHelper(childs, tag, name+".", names)
+			}
+		}
+	}
+}
+
 func isPrimitive(v reflect.Type) bool {
 	return v.Kind() < reflect.Array || v.Kind() == reflect.String
 }
@@ -3,8 +3,8 @@ package aconfig
 import (
 	"encoding"
 	"fmt"
+	"math"
 	"reflect"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -16,7 +16,11 @@ type fieldData struct {
 	value      reflect.Value
 	isSet      bool
 	isRequired bool
+	source     string // where the value is set from, for errors of a file with content.
+	content    any    // path to a file with content (`from:"file"` tag), read after all the sources.
 	tags       map[string]string
+	variant    *variantValues
+	ptrs       []reflect.Value // pointers to parent structs allocated by getFieldsHelper.
 }
 
 func (f *fieldData) Name() string {
@@ -34,15 +38,16 @@ func (f *fieldData) Parent() (Field, bool) {
 	return f.parent, f.parent != nil
 }
 
+func (f *fieldData) Defaults() map[string]string {
+	return tagVariants(f.field.Tag, "default")
+}
+
 func (l *Loader) newSimpleFieldData(value reflect.Value) *fieldData {
 	return l.newFieldData(reflect.StructField{}, value, nil)
 }
 
 func (l *Loader) newFieldData(field reflect.StructField, value reflect.Value, parent *fieldData) *fieldData {
 	requiredTag := field.Tag.Get("required")
-	if requiredTag != "" && requiredTag != "true" {
-		panic(fmt.Sprintf("aconfig: incorrect value for 'required' tag: %v", requiredTag))
-	}
 
 	fd := &fieldData{
 		name:       makeName(field.Name, parent),
@@ -99,7 +104,7 @@ func (l *Loader) fullTag(prefix string, f *fieldData, tag string) string {
 	return prefix + res
 }
 
-func (l *Loader) getFields(x interface{}) []*fieldData {
+func (l *Loader) getFields(x interface{}) ([]*fieldData, error) {
 	value := reflect.ValueOf(x)
 	for value.Type().Kind() == reflect.Ptr {
 		value = value.Elem()
@@ -107,7 +112,7 @@ func (l *Loader) getFields(x interface{}) []*fieldData {
 	return l.getFieldsHelper(value, nil)
 }
 
-func (l *Loader) getFieldsHelper(valueObject reflect.Value, parent *fieldData) []*fieldData {
+func (l *Loader) getFieldsHelper(valueObject reflect.Value, parent *fieldData) ([]*fieldData, error) {
 	typeObject := valueObject.Type()
 	count := valueObject.NumField()
 
@@ -121,44 +126,113 @@ func (l *Loader) getFieldsHelper(valueObject reflect.Value, parent *fieldData) [
 		}
 
 		fd := l.newFieldData(field, value, parent)
+		if requiredTag := field.Tag.Get("required"); requiredTag != "" && requiredTag != "true" {
+			return nil, &FieldError{
+				Path:   fd.name,
+				Source: "tag",
+				Value:  requiredTag,
+				Err:    fmt.Errorf("%w: 'required' can be only 'true', got %q", ErrBadTag, requiredTag),
+			}
+		}
 
 		// if it's a struct - expand and process it's fields
 		kind := field.Type.Kind()
 		if kind == reflect.Ptr {
 			kind = field.Type.Elem().Kind()
 		}
-		if kind == reflect.Struct {
+		if kind == reflect.Struct && !l.hasTypeDecoder(field.Type) {
 			var subFieldParent *fieldData
 			if field.Anonymous {
 				subFieldParent = parent
 			} else {
 				subFieldParent = fd
 			}
+			ptr := value
 			if field.Type.Kind() == reflect.Ptr {
-				value.Set(reflect.New(field.Type.Elem()))
+				if value.IsNil() {
+					value.Set(reflect.New(field.Type.Elem()))
+				}
 				value = value.Elem()
 			}
-			fields = append(fields, l.getFieldsHelper(value, subFieldParent)...)
+			subFields, err := l.getFieldsHelper(value, subFieldParent)
+			if err != nil {
+				return nil, err
+			}
+			if ptr.Kind() == reflect.Ptr {
+				for _, sf := range subFields {
+					sf.ptrs = append(sf.ptrs, ptr)
+				}
+			}
+			fields = append(fields, subFields...)
 			continue
 		}
+		if hasVariants(field.Type) {
+			fd.variant = newVariantValues(fd.name, field.Tag.Get("discriminator"))
+		}
 		fields = append(fields, fd)
 	}
-	return fields
+	return fields, nil
 }
 
 func (l *Loader) setFieldData(field *fieldData, value interface{}) error {
+	// only a file from the last source is read, see loadContents.
+	if field.field.Tag.Get("from") == "file" && value != nil && value != "" {
+		field.content = value
+		return nil
+	}
+	field.content = nil
+	return l.setFieldValue(field, value)
+}
+
+func (l *Loader) setFieldValue(field *fieldData, value interface{}) error {
+	if opt, ok := asOptional(field.value); ok {
+		return l.setOptional(field, opt, value)
+	}
+	if value == nil {
+		l.setNull(field)
+		return nil
+	}
+	if data, ok, err := readContent(l.fsys, field.field.Tag.Get("from"), field.field.Tag.Get("encoding"), value); ok {
+		if err != nil {
+			return err
+		}
+		if value, err = contentValue(data, field.field.Tag.Get("from"), field.value.Type()); err != nil {
+			return err
+		}
+	}
+	if ok, err := l.setDecoded(field, value); ok {
+		return err
+	}
+
 	// unwrap pointers
 	for field.value.Type().Kind() == reflect.Ptr {
 		if field.value.IsNil() {
 			field.value.Set(reflect.New(field.value.Type().Elem()))
 		}
 		field.value = field.value.Elem()
+
+		if ok, err := l.setDecoded(field, value); ok {
+			return err
+		}
 	}
 
 	if value == "" {
 		return nil
 	}
 
+	if field.value.Type() == timeType {
+		t, err := parseTime(value, field.field.Tag.Get("layout"))
+		if err != nil {
+			return err
+		}
+		field.value.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	if field.field.Tag.Get("unit") == "bytes" {
+		return l.setByteSize(field, fmt.Sprint(value))
+	}
+
 	if field.value.CanAddr() {
 		pv := field.value.Addr().Interface()
 		if v, ok := pv.(encoding.TextUnmarshaler); ok {
@@ -167,33 +241,61 @@ func (l *Loader) setFieldData(field *fieldData, value interface{}) error {
 	}
 
 	switch kind := field.value.Type().Kind(); kind {
-	case reflect.Bool:
-		return l.setBool(field, fmt.Sprint(value))
-
 	case reflect.String:
 		return l.setString(field, fmt.Sprint(value))
 
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
-		return l.setInt(field, fmt.Sprint(value))
-
 	case reflect.Int64:
-		return l.setInt64(field, fmt.Sprint(value))
+		return l.setInt64(field, value)
 
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		return l.setUint(field, fmt.Sprint(value))
-
-	case reflect.Float32, reflect.Float64:
-		return l.setFloat(field, fmt.Sprint(value))
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return l.setPrimitive(field, value)
 
 	case reflect.Interface:
+		if hasVariants(field.value.Type()) {
+			v, err := decodeVariant(l.config, l.profile, field.value.Type(), field.field.Tag.Get("discriminator"), value)
+			if err != nil || !v.IsValid() {
+				return err
+			}
+			field.value.Set(v)
+			return nil
+		}
 		return l.setInterface(field, value)
 
 	case reflect.Struct:
+		m, err := mii(value)
+		if err != nil {
+			return err
+		}
 		fd := l.newFieldData(reflect.StructField{}, field.value, nil)
-		return l.m2s(mii(value), fd.value)
+		return l.m2s(m, fd.value)
+
+	case reflect.Array:
+		if reflect.TypeOf(value) == field.value.Type() {
+			field.value.Set(reflect.ValueOf(value))
+			return nil
+		}
+		return l.setArray(field, value)
 
 	case reflect.Slice:
-		if field.field.Type.Elem().Kind() == reflect.Struct {
+		if reflect.TypeOf(value) == field.value.Type() && field.value.Type().Elem().Kind() == reflect.Uint8 {
+			field.value.Set(reflect.ValueOf(value))
+			return nil
+		}
+		elemType := field.value.Type().Elem()
+		if values, ok := value.([]interface{}); ok && (l.hasTypeDecoder(elemType) || isNestedList(elemType) || hasVariants(elemType)) {
+			return l.setSliceValues(field, values)
+		}
+		if str, ok := value.(string); ok && (isNestedList(elemType) || hasVariants(elemType)) {
+			values, err := listItems(str)
+			if err != nil {
+				return err
+			}
+			return l.setSliceValues(field, values)
+		}
+		if field.field.Type.Elem().Kind() == reflect.Struct && !l.hasTypeDecoder(field.field.Type.Elem()) {
 			if value == nil {
 				return nil
 			}
@@ -201,7 +303,10 @@ func (l *Loader) setFieldData(field *fieldData, value interface{}) error {
 			if v, ok := value.([]interface{}); ok {
 				slice := reflect.MakeSlice(field.field.Type, len(v), len(v))
 				for i, val := range v {
-					vv := mii(val)
+					vv, err := mii(val)
+					if err != nil {
+						return err
+					}
 
 					fd := l.newFieldData(reflect.StructField{}, slice.Index(i), nil)
 					if err := l.m2s(vv, fd.value); err != nil {
@@ -214,7 +319,7 @@ func (l *Loader) setFieldData(field *fieldData, value interface{}) error {
 
 			v, ok := value.([]map[string]interface{})
 			if !ok {
-				panic(fmt.Errorf("%T %v", value, value))
+				return fmt.Errorf("want a list of objects, got %T", value)
 			}
 
 			slice := reflect.MakeSlice(field.field.Type, len(v), len(v))
@@ -228,7 +333,11 @@ func (l *Loader) setFieldData(field *fieldData, value interface{}) error {
 
 			return nil
 		}
-		return l.setSlice(field, sliceToString(value))
+		str, err := sliceToString(value)
+		if err != nil {
+			return err
+		}
+		return l.setSlice(field, str)
 
 	case reflect.Map:
 		v, ok := value.(map[string]interface{})
@@ -246,7 +355,7 @@ func (l *Loader) setFieldData(field *fieldData, value interface{}) error {
 			fdv := l.newFieldData(reflect.StructField{}, reflect.New(field.value.Type().Elem()).Elem(), field)
 			fdv.field.Type = field.value.Type().Elem()
 			if err := l.setFieldData(fdv, val); err != nil {
-				return fmt.Errorf("incorrect map value %q: %w", val, err)
+				return fmt.Errorf("incorrect map value %v: %w", val, err)
 			}
 
 			mapp.SetMapIndex(fdk.value, fdv.value)
@@ -259,60 +368,167 @@ func (l *Loader) setFieldData(field *fieldData, value interface{}) error {
 	}
 }
 
-func (*Loader) setBool(field *fieldData, value string) error {
-	val, err := strconv.ParseBool(value)
-	if err != nil {
+// setNull sets a field to its zero value for null from a file,
+// structs and pointers to structs keep their nested values.
+func (*Loader) setNull(field *fieldData) {
+	typ := field.value.Type()
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || typ == timeType {
+		field.value.Set(reflect.Zero(field.value.Type()))
+	}
+}
+
+// setOptional sets the value of Optional with the field tags and marks it as set.
+// null from a file makes it unset.
+func (l *Loader) setOptional(field *fieldData, opt optionalValue, value interface{}) error {
+	if value == nil {
+		field.value.Set(reflect.Zero(field.value.Type()))
+		return nil
+	}
+	fd := l.newFieldData(field.field, reflect.ValueOf(opt.valuePtr()).Elem(), field.parent)
+	fd.field.Type = fd.value.Type()
+	if err := l.setFieldData(fd, value); err != nil {
 		return err
 	}
-	field.value.SetBool(val)
+	opt.markSet()
 	return nil
 }
 
-func (*Loader) setInt(field *fieldData, value string) error {
-	val, err := strconv.ParseInt(value, 0, field.value.Type().Bits())
+// resetNilPointers sets pointers to structs back to nil if none of their fields is set.
+func (l *Loader) resetNilPointers() {
+	used := map[any]bool{}
+	for _, field := range l.fields {
+		for _, ptr := range field.ptrs {
+			key := ptr.Addr().Interface()
+			used[key] = used[key] || field.isSet
+		}
+	}
+	for _, field := range l.fields {
+		for _, ptr := range field.ptrs {
+			if !used[ptr.Addr().Interface()] {
+				ptr.Set(reflect.Zero(ptr.Type()))
+			}
+		}
+	}
+}
+
+// setDecoded sets a value with a decoder from Config.TypeDecoders.
+// Returns false if there is no decoder for the field type or the value is empty.
+func (l *Loader) setDecoded(field *fieldData, value interface{}) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	dec, ok := typeDecoder(l.config.TypeDecoders, field.value.Type())
+	if !ok {
+		return false, nil
+	}
+
+	v, err := dec(value)
 	if err != nil {
-		return err
+		return true, err
+	}
+	field.value.Set(reflect.ValueOf(v))
+	return true, nil
+}
+
+func (l *Loader) hasTypeDecoder(typ reflect.Type) bool {
+	return hasTypeDecoder(l.config.TypeDecoders, typ)
+}
+
+// setSliceValues sets every raw value as a slice element.
+func (l *Loader) setSliceValues(field *fieldData, values []interface{}) error {
+	slice := reflect.MakeSlice(field.value.Type(), len(values), len(values))
+	for i, val := range values {
+		fd := l.newSimpleFieldData(slice.Index(i))
+		fd.field.Type = field.value.Type().Elem()
+		fd.field.Tag = field.field.Tag
+		if err := l.setFieldData(fd, val); err != nil {
+			return fmt.Errorf("incorrect slice item %v: %w", val, err)
+		}
 	}
-	field.value.SetInt(val)
+	field.value.Set(slice)
 	return nil
 }
 
-func (l *Loader) setInt64(field *fieldData, value string) error {
-	if field.field.Type == reflect.TypeOf(time.Second) {
-		val, err := time.ParseDuration(value)
-		if err != nil {
-			return err
+// setByteSize sets an integer field with a size like 64MiB, see ByteSize.
+func (*Loader) setByteSize(field *fieldData, value string) error {
+	size, err := parseByteSize(value)
+	if err != nil {
+		return err
+	}
+
+	switch field.value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if size > math.MaxInt64 || field.value.OverflowInt(int64(size)) {
+			return fmt.Errorf("size %q overflows %v", value, field.value.Type())
 		}
-		field.value.Set(reflect.ValueOf(val))
-		return nil
+		field.value.SetInt(int64(size))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if field.value.OverflowUint(size) {
+			return fmt.Errorf("size %q overflows %v", value, field.value.Type())
+		}
+		field.value.SetUint(size)
+	default:
+		return fmt.Errorf("'unit' tag is supported only for integers, got %v", field.value.Type())
 	}
-	return l.setInt(field, value)
+	return nil
 }
 
-func (*Loader) setUint(field *fieldData, value string) error {
-	val, err := strconv.ParseUint(value, 0, field.value.Type().Bits())
+// setArray sets every item of a list, the number of items must be equal to the array length.
+func (l *Loader) setArray(field *fieldData, value interface{}) error {
+	items, err := listItems(value)
 	if err != nil {
 		return err
 	}
-	field.value.SetUint(val)
+	if n := field.value.Len(); len(items) != n {
+		return fmt.Errorf("want %d items, got %d", n, len(items))
+	}
+
+	for i, item := range items {
+		fd := l.newSimpleFieldData(field.value.Index(i))
+		fd.field.Type = field.value.Type().Elem()
+		fd.field.Tag = field.field.Tag
+		if err := l.setFieldData(fd, item); err != nil {
+			return fmt.Errorf("incorrect array item %v: %w", item, err)
+		}
+	}
 	return nil
 }
 
-func (*Loader) setFloat(field *fieldData, value string) error {
-	val, err := strconv.ParseFloat(value, field.value.Type().Bits())
+// setPrimitive sets a bool, integer or float, values from file decoders are kept typed,
+// strings from env, flags and tags are parsed.
+func (*Loader) setPrimitive(field *fieldData, value interface{}) error {
+	val, err := convertPrimitive(value, field.value.Type())
 	if err != nil {
 		return err
 	}
-	field.value.SetFloat(val)
+	field.value.Set(reflect.ValueOf(val))
 	return nil
 }
 
+func (l *Loader) setInt64(field *fieldData, value interface{}) error {
+	if str, ok := value.(string); ok && field.field.Type == reflect.TypeOf(time.Second) {
+		val, err := parseDuration(str)
+		if err != nil {
+			return err
+		}
+		field.value.Set(reflect.ValueOf(val))
+		return nil
+	}
+	return l.setPrimitive(field, value)
+}
+
 func (*Loader) setString(field *fieldData, value string) error {
 	field.value.SetString(value)
 	return nil
 }
 
 func (*Loader) setInterface(field *fieldData, value interface{}) error {
+	if value == nil {
+		return nil
+	}
 	field.value.Set(reflect.ValueOf(value))
 	return nil
 }
@@ -332,8 +548,9 @@ func (l *Loader) setSlice(field *fieldData, value string) error {
 
 		fd := l.newFieldData(reflect.StructField{}, slice.Index(i), nil)
 		fd.field.Type = field.field.Type.Elem()
+		fd.field.Tag = field.field.Tag
 		if err := l.setFieldData(fd, val); err != nil {
-			return fmt.Errorf("incorrect slice item %q: %w", val, err)
+			return fmt.Errorf("incorrect slice item %v: %w", val, err)
 		}
 	}
 	field.value.Set(slice)
@@ -360,7 +577,7 @@ func (l *Loader) setMap(field *fieldData, value string) error {
 		fdv := l.newFieldData(reflect.StructField{}, reflect.New(field.value.Type().Elem()).Elem(), field)
 		fdv.field.Type = field.value.Type().Elem()
 		if err := l.setFieldData(fdv, val); err != nil {
-			return fmt.Errorf("incorrect map value %q: %w", val, err)
+			return fmt.Errorf("incorrect map value %v: %w", val, err)
 		}
 		mapField.SetMapIndex(fdk.value, fdv.value)
 	}
@@ -380,10 +597,34 @@ func (l *Loader) m2s(m map[string]interface{}, structValue reflect.Value) error
 			return fmt.Errorf("cannot set %q field value", name)
 		}
 
+		if l.hasTypeDecoder(structFieldValue.Type()) {
+			fd := l.newSimpleFieldData(structFieldValue)
+			fd.field, _ = structValue.Type().FieldByName(name)
+			if err := l.setFieldData(fd, value); err != nil {
+				return fmt.Errorf("field %q: %w", name, err)
+			}
+			continue
+		}
+
 		val := reflect.ValueOf(value)
+		if !val.IsValid() {
+			l.setNull(l.newSimpleFieldData(structFieldValue))
+			continue
+		}
+		if structFieldValue.Type() != val.Type() && isPrimitive(structFieldValue.Type()) && isPrimitive(val.Type()) {
+			fd := l.newSimpleFieldData(structFieldValue)
+			fd.field, _ = structValue.Type().FieldByName(name)
+			if err := l.setFieldData(fd, value); err != nil {
+				return fmt.Errorf("field %q: %w", name, err)
+			}
+			continue
+		}
 		if structFieldValue.Type() != val.Type() {
 			if structFieldValue.Kind() == reflect.Slice && val.Kind() == reflect.Slice {
-				vals := value.([]interface{})
+				vals, ok := value.([]interface{})
+				if !ok {
+					return fmt.Errorf("field %q: want a list, got %T", name, value)
+				}
 				slice := reflect.MakeSlice(structFieldValue.Type(), len(vals), len(vals))
 				if isPrimitive(structFieldValue.Type().Elem()) {
 					for i := 0; i < len(vals); i++ {
@@ -394,7 +635,10 @@ func (l *Loader) m2s(m map[string]interface{}, structValue reflect.Value) error
 					}
 				} else {
 					for i := 0; i < len(vals); i++ {
-						a := mii(vals[i])
+						a, err := mii(vals[i])
+						if err != nil {
+							return fmt.Errorf("field %q: %w", name, err)
+						}
 						b := slice.Index(i)
 						if err := l.m2s(a, b); err != nil {
 							return err
@@ -413,17 +657,17 @@ func (l *Loader) m2s(m map[string]interface{}, structValue reflect.Value) error
 	return nil
 }
 
-func mii(m interface{}) map[string]interface{} {
+func mii(m interface{}) (map[string]interface{}, error) {
 	switch m := m.(type) {
 	case map[string]interface{}:
-		return m
+		return m, nil
 	case map[interface{}]interface{}:
 		res := map[string]interface{}{}
 		for k, v := range m {
-			res[k.(string)] = v
+			res[fmt.Sprint(k)] = v
 		}
-		return res
+		return res, nil
 	default:
-		panic(fmt.Sprintf("%T %v", m, m))
+		return nil, fmt.Errorf("want an object, got %T", m)
 	}
 }
@@ -0,0 +1,79 @@
+package aconfig
+
+import (
+	"reflect"
+)
+
+// isReloadable reports whether a field can be changed without restart.
+// Fields are reloadable by default, `reload:"false"` on a field or on any parent struct disables it.
+func isReloadable(fd *fieldData) bool {
+	for f := fd; f != nil; f = f.parent {
+		if f.field.Tag.Get("reload") == "false" {
+			return false
+		}
+	}
+	return true
+}
+
+// restartError returns *LoadError for every changed non-reloadable field, nil if there are none.
+func restartError(oldValue, newValue reflect.Value) error {
+	changes := diffValues(oldValue, newValue, func(fd *fieldData) bool {
+		return !isReloadable(fd)
+	})
+	if len(changes) == 0 {
+		return nil
+	}
+
+	errs := make([]*FieldError, len(changes))
+	for i, change := range changes {
+		errs[i] = &FieldError{
+			Path:   change.Path,
+			Source: "reload",
+			Value:  change.New,
+			Err:    ErrRestartRequired,
+		}
+	}
+	return &LoadError{Errors: errs}
+}
+
+// keepNotReloadable copies non-reloadable fields from old to new, new must be addressable.
+func keepNotReloadable(oldValue, newValue reflect.Value) {
+	typeObject := newValue.Type()
+
+	for i := 0; i < typeObject.NumField(); i++ {
+		field := typeObject.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		oldField, newField := oldValue.Field(i), newValue.Field(i)
+
+		if field.Tag.Get("reload") == "false" {
+			newField.Set(oldField)
+			continue
+		}
+
+		typ := field.Type
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct || isTextUnmarshaler(typ) || hasTypeDecoder(nil, typ) {
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Ptr {
+			if oldField.IsNil() && newField.IsNil() {
+				continue
+			}
+			if oldField.IsNil() {
+				oldField = reflect.Zero(typ)
+			} else {
+				oldField = oldField.Elem()
+			}
+			if newField.IsNil() {
+				newField.Set(reflect.New(typ))
+			}
+			newField = newField.Elem()
+		}
+		keepNotReloadable(oldField, newField)
+	}
+}
@@ -0,0 +1,79 @@
+package aconfig
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+)
+
+// SignalConfig to configure reload on signals.
+type SignalConfig struct {
+	// Signals that trigger reload. Default is SIGHUP.
+	// Must be set on platforms without SIGHUP (js/wasm, wasip1, plan9).
+	Signals []os.Signal
+
+	// OnReload is called after every reload, err is nil on success.
+	// Reload with ErrRestartRequired error is still applied, see Value.Reload.
+	OnReload func(err error)
+
+	// Logger to report reloads. Nothing is logged if nil.
+	Logger *slog.Logger
+}
+
+// ReloadOnSignal reloads configuration every time one of the signals is received.
+// Invalid configuration, including one rejected by Value.Validate, never replaces the current one.
+// Signals are handled until ctx is done.
+func (v *Value[T]) ReloadOnSignal(ctx context.Context, cfg SignalConfig) {
+	signals := cfg.Signals
+	if len(signals) == 0 {
+		signals = defaultSignals
+	}
+	if len(signals) == 0 {
+		if cfg.Logger != nil {
+			cfg.Logger.ErrorContext(ctx, "config reload on signal is disabled, no signals are set")
+		}
+		return
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+
+	go func() {
+		defer signal.Stop(ch)
+		v.reloadOn(ctx, ch, cfg)
+	}()
+}
+
+func (v *Value[T]) reloadOn(ctx context.Context, ch <-chan os.Signal, cfg SignalConfig) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-ch:
+			err := v.Reload()
+			logReload(ctx, cfg.Logger, sig, err)
+			if cfg.OnReload != nil {
+				cfg.OnReload(err)
+			}
+		}
+	}
+}
+
+func logReload(ctx context.Context, logger *slog.Logger, sig os.Signal, err error) {
+	if logger == nil {
+		return
+	}
+
+	switch {
+	case err == nil:
+		logger.InfoContext(ctx, "config reloaded", slog.String("signal", sig.String()))
+	case errors.Is(err, ErrRestartRequired):
+		logger.WarnContext(ctx, "config reloaded, some fields require restart",
+			slog.String("signal", sig.String()), slog.Any("error", err))
+	default:
+		logger.ErrorContext(ctx, "config reload failed, keeping the current one",
+			slog.String("signal", sig.String()), slog.Any("error", err))
+	}
+}
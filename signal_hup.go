@@ -0,0 +1,10 @@
+//go:build unix || windows
+
+package aconfig
+
+import (
+	"os"
+	"syscall"
+)
+
+var defaultSignals = []os.Signal{syscall.SIGHUP}
@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package aconfig
+
+import "os"
+
+// there is no SIGHUP on js/wasm, wasip1 and plan9, SignalConfig.Signals must be set.
+var defaultSignals []os.Signal
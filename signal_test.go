@@ -0,0 +1,92 @@
+//go:build unix
+
+package aconfig
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestReloadOnSignal(t *testing.T) {
+	type TestConfig struct {
+		Port int
+	}
+
+	var mu sync.Mutex
+	fsys := fstest.MapFS{
+		"config.json": &fstest.MapFile{Data: []byte(`{"port": 80}`)},
+	}
+	setFile := func(data string) {
+		mu.Lock()
+		defer mu.Unlock()
+		fsys["config.json"] = &fstest.MapFile{Data: []byte(data)}
+	}
+
+	loader := LoaderFor(&TestConfig{}, Config{
+		NewParser:  newParser,
+		SkipEnv:    true,
+		SkipFlags:  true,
+		Files:      []string{"config.json"},
+		FileSystem: lockedFS{fsys: fsys, mu: &mu},
+	})
+	value, err := NewValue[TestConfig](loader)
+	failIfErr(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var logs bytes.Buffer
+	errs := make(chan error, 1)
+	value.ReloadOnSignal(ctx, SignalConfig{
+		OnReload: func(err error) { errs <- err },
+		Logger:   slog.New(slog.NewTextHandler(&logs, nil)),
+	})
+
+	sighup := func() error {
+		t.Helper()
+		p, err := os.FindProcess(os.Getpid())
+		failIfErr(t, err)
+		failIfErr(t, p.Signal(syscall.SIGHUP))
+
+		select {
+		case err := <-errs:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("no reload")
+			return nil
+		}
+	}
+
+	setFile(`{"port": 8080}`)
+	failIfErr(t, sighup())
+	mustEqual(t, value.Get().Port, 8080)
+
+	setFile(`{"port": "abc"}`)
+	failIfOk(t, sighup())
+	mustEqual(t, value.Get().Port, 8080)
+
+	if !strings.Contains(logs.String(), `msg="config reloaded"`) ||
+		!strings.Contains(logs.String(), `msg="config reload failed, keeping the current one"`) {
+		t.Fatalf("unexpected logs: %s", logs.String())
+	}
+}
+
+type lockedFS struct {
+	fsys fstest.MapFS
+	mu   *sync.Mutex
+}
+
+func (l lockedFS) Open(name string) (fs.File, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fsys.Open(name)
+}
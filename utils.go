@@ -1,12 +1,17 @@
 package aconfig
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io/fs"
+	"log/slog"
 	"os"
+	"path"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -151,6 +156,246 @@ func (f *fsOrOS) Open(name string) (fs.File, error) {
 	return f.FS.Open(name)
 }
 
+// readKeyPerFile reads a directory where each file name is a key and file content is a value.
+// When kubelet '..data' directory is present, entries are read from it, because
+// top-level entries are just symlinks to it.
+func readKeyPerFile(fsys fs.FS, dir string) (map[string]interface{}, error) {
+	if info, err := fs.Stat(fsys, path.Join(dir, "..data")); err == nil && info.IsDir() {
+		dir = path.Join(dir, "..data")
+	}
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]interface{}, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		file := path.Join(dir, name)
+		info, err := fs.Stat(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		value := strings.TrimSuffix(string(data), "\n")
+		res[name] = strings.TrimSuffix(value, "\r")
+	}
+	return res, nil
+}
+
+// defaultTag returns `default.<profile>` tag value if it's set, otherwise `default` tag value.
+func defaultTag(tag reflect.StructTag, profile string) string {
+	if profile != "" {
+		if v, ok := tag.Lookup("default." + profile); ok {
+			return v
+		}
+	}
+	return tag.Get("default")
+}
+
+// withDefaultVariants adds `default.<profile>` values to the usage.
+func withDefaultVariants(usage string, tag reflect.StructTag) string {
+	variants := tagVariants(tag, "default")
+	if len(variants) == 0 {
+		return usage
+	}
+
+	profiles := make([]string, 0, len(variants))
+	for profile := range variants {
+		profiles = append(profiles, profile)
+	}
+	sort.Strings(profiles)
+
+	b := &strings.Builder{}
+	b.WriteString(usage)
+	if usage != "" {
+		b.WriteByte(' ')
+	}
+	b.WriteByte('(')
+	for i, profile := range profiles {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(b, "%s %q", profile, variants[profile])
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// tagVariants returns values of `<key>.<variant>:"value"` tags by variant.
+// Parsing is the same as in reflect.StructTag.Lookup.
+func tagVariants(tag reflect.StructTag, key string) map[string]string {
+	var res map[string]string
+	prefix := key + "."
+
+	for tag != "" {
+		i := 0
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+		tag = tag[i:]
+		if tag == "" {
+			break
+		}
+
+		i = 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			break
+		}
+		name := string(tag[:i])
+		tag = tag[i+1:]
+
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			break
+		}
+		qvalue := string(tag[:i+1])
+		tag = tag[i+1:]
+
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		value, err := strconv.Unquote(qvalue)
+		if err != nil {
+			break
+		}
+		if res == nil {
+			res = map[string]string{}
+		}
+		res[strings.TrimPrefix(name, prefix)] = value
+	}
+	return res
+}
+
+// suggestName returns the closest name from known names or empty string if nothing is close enough.
+func suggestName(name string, known []string) string {
+	maxDist := len(name) / 3
+	if maxDist < 1 {
+		maxDist = 1
+	}
+
+	best, bestDist := "", maxDist+1
+	for _, candidate := range known {
+		if candidate == name {
+			continue
+		}
+		dist := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if dist < bestDist || (dist == bestDist && candidate < best) {
+			best, bestDist = candidate, dist
+		}
+	}
+	return best
+}
+
+// editDistance between 2 strings, a transposition of 2 adjacent characters counts as 1 edit.
+// See https://en.wikipedia.org/wiki/Damerau%E2%80%93Levenshtein_distance
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, minInt(curr[j-1]+1, prev[j-1]+cost))
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = minInt(curr[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(rb)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// profileFile returns a profile overlay for a file: config.yaml -> config.prod.yaml, .env -> .env.prod.
+func profileFile(file, profile string) string {
+	dir, base := path.Split(file)
+	ext := path.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+	if name == "" {
+		return file + "." + profile
+	}
+	return dir + name + "." + profile + ext
+}
+
+// withoutProfileFiles removes 'name.<profile>.ext' files when 'name.ext' is in the list too.
+func withoutProfileFiles(files []string) []string {
+	set := make(map[string]struct{}, len(files))
+	for _, file := range files {
+		set[file] = struct{}{}
+	}
+
+	res := make([]string, 0, len(files))
+	for _, file := range files {
+		dir, base := path.Split(file)
+		ext := path.Ext(base)
+		name := strings.TrimSuffix(base, ext)
+		if i := strings.LastIndexByte(name, '.'); i > 0 {
+			if _, ok := set[dir+name[:i]+ext]; ok {
+				continue
+			}
+		}
+		res = append(res, file)
+	}
+	return res
+}
+
+func toStringMap(v interface{}) (map[string]interface{}, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return v, true
+	case map[interface{}]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			res[fmt.Sprint(k)] = val
+		}
+		return res, true
+	default:
+		return nil, false
+	}
+}
+
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
 type jsonDecoder struct {
 	fsys fs.FS
 }
@@ -173,27 +418,141 @@ func (d *jsonDecoder) DecodeFile(filename string) (map[string]interface{}, error
 	defer f.Close()
 
 	var raw map[string]interface{}
-	if err := json.NewDecoder(f).Decode(&raw); err != nil {
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
 		return nil, err
 	}
+	normalizeNumbers(raw)
 	return raw, nil
 }
 
-func sliceToString(curr interface{}) string {
+// DecodeFileWithPositions implements PositionalDecoder.
+func (d *jsonDecoder) DecodeFileWithPositions(filename string) (map[string]interface{}, map[string]Position, error) {
+	data, err := fs.ReadFile(d.fsys, filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var raw map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, nil, err
+	}
+	normalizeNumbers(raw)
+
+	positions := map[string]Position{}
+	_ = jsonPositions(json.NewDecoder(bytes.NewReader(data)), data, "", positions)
+	return raw, positions, nil
+}
+
+// jsonPositions collects positions of the keys for a next JSON value in the decoder.
+func jsonPositions(dec *json.Decoder, data []byte, prefix string, positions map[string]Position) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return nil
+	}
+
+	switch delim {
+	case '{':
+		for dec.More() {
+			offset := int(dec.InputOffset())
+			for offset < len(data) && strings.IndexByte(" \t\r\n,", data[offset]) != -1 {
+				offset++
+			}
+
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			name := prefix + fmt.Sprint(key)
+			positions[name] = textPosition(data, offset)
+
+			if err := jsonPositions(dec, data, name+".", positions); err != nil {
+				return err
+			}
+		}
+	case '[':
+		for i := 0; dec.More(); i++ {
+			if err := jsonPositions(dec, data, prefix+strconv.Itoa(i)+".", positions); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = dec.Token() // closing delimiter
+	return err
+}
+
+// textPosition returns line and column for a byte offset in the text.
+func textPosition(data []byte, offset int) Position {
+	line := 1 + bytes.Count(data[:offset], []byte("\n"))
+	column := offset - bytes.LastIndexByte(data[:offset], '\n')
+	return Position{Line: line, Column: column}
+}
+
+func sliceToString(curr interface{}) (string, error) {
 	switch curr := curr.(type) {
 	case []interface{}:
 		b := &strings.Builder{}
 		for i, v := range curr {
+			if v == nil {
+				return "", fmt.Errorf("want a value, got null at index %d", i)
+			}
 			if i > 0 {
 				b.WriteByte(',')
 			}
 			fmt.Fprint(b, v)
 		}
-		return b.String()
+		return b.String(), nil
 	case string:
-		return curr
+		return curr, nil
 	default:
-		panic(fmt.Sprintf("can't normalize %T %v", curr, curr))
+		return "", fmt.Errorf("want a list or a string, got %T", curr)
+	}
+}
+
+// listItems returns items of a list from a file or of a comma-separated string.
+// Strings starting with '[' are decoded as JSON, like `default:"[[1, 2], [3]]"` for nested lists.
+func listItems(value interface{}) ([]interface{}, error) {
+	switch value := value.(type) {
+	case []interface{}:
+		return value, nil
+	case string:
+		str := strings.TrimSpace(value)
+		if strings.HasPrefix(str, "[") {
+			var items []interface{}
+			if err := json.Unmarshal([]byte(str), &items); err != nil {
+				return nil, fmt.Errorf("want a JSON list: %w", err)
+			}
+			return items, nil
+		}
+		if str == "" {
+			return []interface{}{}, nil
+		}
+		parts := strings.Split(str, ",")
+		items := make([]interface{}, len(parts))
+		for i, part := range parts {
+			items[i] = strings.TrimSpace(part)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("want a list or a string, got %T", value)
+	}
+}
+
+// isNestedList reports whether a list element is a list or a map itself.
+func isNestedList(elem reflect.Type) bool {
+	switch elem.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	default:
+		return false
 	}
 }
 
@@ -237,6 +596,69 @@ func find(actualFields map[string]interface{}, name string) map[string]interface
 			}
 		}
 		delete(actualFields, subName)
+	case nil:
+		// null for a struct keeps its nested values.
+		delete(actualFields, subName)
 	}
 	return actualFields
 }
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// logFieldSet logs a field set by a source, secret values are masked.
+func logFieldSet(logger *slog.Logger, path, source string, value any, secret bool) {
+	if logger == nil {
+		return
+	}
+	if secret {
+		value = SecretMask
+	}
+	logger.Debug("field set", "field", path, "source", source, "value", value)
+}
+
+// logUnknown logs ignored unknown keys with a prefix, values are not logged as they can be secret.
+func logUnknown(logger *slog.Logger, source string, values map[string]any, prefix string) {
+	if logger == nil {
+		return
+	}
+	for _, name := range sortedKeys(values) {
+		if strings.HasPrefix(name, prefix) {
+			logger.Debug("unknown key ignored", "key", name, "source", source)
+		}
+	}
+}
+
+// copyValue returns a copy of a value, structs behind pointers are copied too,
+// so loading into the copy doesn't change the original value.
+func copyValue(v reflect.Value) reflect.Value {
+	res := reflect.New(v.Type()).Elem()
+	res.Set(v)
+	copyPointers(res)
+	return res
+}
+
+func copyPointers(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			return
+		}
+		ptr := reflect.New(v.Type().Elem())
+		ptr.Elem().Set(v.Elem())
+		v.Set(ptr)
+		copyPointers(ptr.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).CanSet() {
+				copyPointers(v.Field(i))
+			}
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package aconfig
 
 import (
+	"bytes"
+	"encoding/json"
 	"reflect"
 	"testing"
 )
@@ -33,3 +35,67 @@ func Test_splitNameByWords(t *testing.T) {
 		})
 	}
 }
+
+func Test_profileFile(t *testing.T) {
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"config.yaml", "config.prod.yaml"},
+		{"etc/app/config.json", "etc/app/config.prod.json"},
+		{".env", ".env.prod"},
+		{"etc/.env", "etc/.env.prod"},
+		{"config", "config.prod"},
+	}
+	for _, tt := range tests {
+		if got := profileFile(tt.file, "prod"); got != tt.want {
+			t.Errorf("profileFile(%q) = %v, want %v", tt.file, got, tt.want)
+		}
+	}
+}
+
+func Test_suggestName(t *testing.T) {
+	known := []string{"APP_DB_HOST", "APP_DB_PORT", "APP_DB_USER", "db", "db.host"}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"APP_DB_HSOT", "APP_DB_HOST"},
+		{"APP_DB_PROT", "APP_DB_PORT"},
+		{"APP_DB_USERS", "APP_DB_USER"},
+		{"db.hots", "db.host"},
+		{"bd", "db"},
+		{"APP_LOG_LEVEL", ""},
+		{"x", ""},
+	}
+	for _, tt := range tests {
+		if got := suggestName(tt.name, known); got != tt.want {
+			t.Errorf("suggestName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_jsonPositions(t *testing.T) {
+	data := []byte(`{
+  "a": 1,
+  "b": {"c": [1, {"d": true}]},
+	"e": "f"
+}`)
+
+	positions := map[string]Position{}
+	if err := jsonPositions(json.NewDecoder(bytes.NewReader(data)), data, "", positions); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]Position{
+		"a":       {Line: 2, Column: 3},
+		"b":       {Line: 3, Column: 3},
+		"b.c":     {Line: 3, Column: 9},
+		"b.c.1.d": {Line: 3, Column: 19},
+		"e":       {Line: 4, Column: 2},
+	}
+	if !reflect.DeepEqual(positions, want) {
+		t.Fatalf("have %+v\nwant %+v", positions, want)
+	}
+}
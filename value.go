@@ -0,0 +1,89 @@
+package aconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Value holds the current configuration and allows to reload it safely.
+// Every reload loads a fresh config and swaps it atomically,
+// so readers never see a partially loaded config.
+type Value[T any] struct {
+	loader  *Loader
+	current atomic.Pointer[T]
+
+	mu         sync.Mutex // serializes reloads, validators and subscribers.
+	validators []func(T) error
+	subs       []func(old, new T)
+}
+
+// NewValue loads configuration with a loader and returns a Value with it.
+// Loader must be created for *T.
+func NewValue[T any](loader *Loader) (*Value[T], error) {
+	v := &Value[T]{loader: loader}
+	cfg := new(T)
+	if err := loader.LoadInto(cfg); err != nil {
+		return nil, err
+	}
+	v.current.Store(cfg)
+	return v, nil
+}
+
+// Get returns the current configuration.
+func (v *Value[T]) Get() T {
+	return *v.current.Load()
+}
+
+// Subscribe adds a function that is called after every successful reload.
+// Functions are called in the order they were added, one reload at a time.
+func (v *Value[T]) Subscribe(fn func(old, new T)) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.subs = append(v.subs, fn)
+}
+
+// Validate adds a function that checks every reloaded configuration before it's applied.
+// If one of the functions returns an error, Reload returns it and keeps the current configuration.
+func (v *Value[T]) Validate(fn func(cfg T) error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.validators = append(v.validators, fn)
+}
+
+// Reload loads configuration again and replaces the current one.
+// On error, including an error from a function added with Validate, the current configuration is kept.
+//
+// Fields with `reload:"false"` tag (or inside such struct) keep their current values.
+// If some of them were changed, the rest of the config is still applied
+// and an error with ErrRestartRequired for every such field is returned.
+func (v *Value[T]) Reload() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	cfg := new(T)
+	if err := v.loader.LoadInto(cfg); err != nil {
+		return err
+	}
+
+	old := v.current.Load()
+	oldValue, newValue := reflect.ValueOf(old).Elem(), reflect.ValueOf(cfg).Elem()
+	errRestart := restartError(oldValue, newValue)
+	keepNotReloadable(oldValue, newValue)
+
+	for _, fn := range v.validators {
+		if err := fn(*cfg); err != nil {
+			return fmt.Errorf("validate: %w", err)
+		}
+	}
+	v.current.Store(cfg)
+
+	for _, fn := range v.subs {
+		fn(*old, *cfg)
+	}
+	if errRestart != nil {
+		return fmt.Errorf("reload: %w", errRestart)
+	}
+	return nil
+}
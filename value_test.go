@@ -0,0 +1,170 @@
+package aconfig
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func TestValue(t *testing.T) {
+	type TestConfig struct {
+		Port int `default:"80"`
+		Host string
+	}
+
+	fsys := fstest.MapFS{
+		"config.json": &fstest.MapFile{Data: []byte(`{"port": 8080, "host": "a"}`)},
+	}
+	loader := LoaderFor(&TestConfig{}, Config{
+		NewParser:  newParser,
+		SkipEnv:    true,
+		SkipFlags:  true,
+		Files:      []string{"config.json"},
+		FileSystem: fsys,
+	})
+
+	value, err := NewValue[TestConfig](loader)
+	failIfErr(t, err)
+	mustEqual(t, value.Get(), TestConfig{Port: 8080, Host: "a"})
+
+	var olds, news []TestConfig
+	value.Subscribe(func(old, new TestConfig) {
+		olds = append(olds, old)
+		news = append(news, new)
+	})
+
+	fsys["config.json"] = &fstest.MapFile{Data: []byte(`{"host": "b"}`)}
+	failIfErr(t, value.Reload())
+	mustEqual(t, value.Get(), TestConfig{Port: 80, Host: "b"})
+	mustEqual(t, olds, []TestConfig{{Port: 8080, Host: "a"}})
+	mustEqual(t, news, []TestConfig{{Port: 80, Host: "b"}})
+
+	// bad config keeps the current one.
+	fsys["config.json"] = &fstest.MapFile{Data: []byte(`{"port": "abc"}`)}
+	failIfOk(t, value.Reload())
+	mustEqual(t, value.Get(), TestConfig{Port: 80, Host: "b"})
+	mustEqual(t, len(news), 1)
+
+	// config rejected by a validator keeps the current one.
+	errNoHost := errors.New("host is required")
+	value.Validate(func(cfg TestConfig) error {
+		if cfg.Host == "" {
+			return errNoHost
+		}
+		return nil
+	})
+	fsys["config.json"] = &fstest.MapFile{Data: []byte(`{"port": 9090}`)}
+	err = value.Reload()
+	if !errors.Is(err, errNoHost) {
+		t.Fatalf("want validation error, got %v", err)
+	}
+	mustEqual(t, value.Get(), TestConfig{Port: 80, Host: "b"})
+	mustEqual(t, len(news), 1)
+
+	fsys["config.json"] = &fstest.MapFile{Data: []byte(`{"port": 9090, "host": "c"}`)}
+	failIfErr(t, value.Reload())
+	mustEqual(t, value.Get(), TestConfig{Port: 9090, Host: "c"})
+}
+
+func TestValueBadType(t *testing.T) {
+	type TestConfig struct {
+		Port int
+	}
+
+	loader := LoaderFor(&TestConfig{}, Config{
+		SkipFiles: true,
+		SkipEnv:   true,
+		SkipFlags: true,
+	})
+
+	_, err := NewValue[struct{ Host string }](loader)
+	failIfOk(t, err)
+}
+
+func TestValueConcurrent(t *testing.T) {
+	type TestConfig struct {
+		A int `default:"1"`
+		B int `default:"1"`
+	}
+
+	loader := LoaderFor(&TestConfig{}, Config{
+		NewParser: newParser,
+		SkipFiles: true,
+		SkipEnv:   true,
+		SkipFlags: true,
+	})
+
+	value, err := NewValue[TestConfig](loader)
+	failIfErr(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := value.Reload(); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if cfg := value.Get(); cfg.A != cfg.B {
+				t.Errorf("got half-loaded config: %+v", cfg)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestValueReloadPolicy(t *testing.T) {
+	type DB struct {
+		Addr     string
+		PoolSize int
+	}
+	type TestConfig struct {
+		Addr     string `reload:"false"`
+		LogLevel string `reload:"true"`
+		Token    string `reload:"false" secret:"true"`
+		DB       DB     `reload:"false"`
+	}
+
+	fsys := fstest.MapFS{
+		"config.json": &fstest.MapFile{Data: []byte(`{"addr": ":80", "log_level": "info", "token": "a"}`)},
+	}
+	loader := LoaderFor(&TestConfig{}, Config{
+		NewParser:  newParser,
+		SkipEnv:    true,
+		SkipFlags:  true,
+		Files:      []string{"config.json"},
+		FileSystem: fsys,
+	})
+
+	value, err := NewValue[TestConfig](loader)
+	failIfErr(t, err)
+
+	fsys["config.json"] = &fstest.MapFile{Data: []byte(`{"addr": ":80", "log_level": "debug", "token": "a"}`)}
+	failIfErr(t, value.Reload())
+	mustEqual(t, value.Get().LogLevel, "debug")
+
+	fsys["config.json"] = &fstest.MapFile{Data: []byte(`{"addr": ":8080", "log_level": "warn", "token": "b", "db": {"pool_size": 10}}`)}
+	err = value.Reload()
+	failIfOk(t, err)
+	if !errors.Is(err, ErrRestartRequired) {
+		t.Fatalf("want ErrRestartRequired, got %v", err)
+	}
+
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("want *LoadError, got %T", err)
+	}
+	mustEqual(t, len(loadErr.Errors), 3)
+	mustEqual(t, loadErr.Errors[0].Path, "Addr")
+	mustEqual(t, loadErr.Errors[0].Value, ":8080")
+	mustEqual(t, loadErr.Errors[1].Path, "Token")
+	mustEqual(t, loadErr.Errors[1].Value, SecretMask)
+	mustEqual(t, loadErr.Errors[2].Path, "DB.PoolSize")
+
+	want := TestConfig{Addr: ":80", LogLevel: "warn", Token: "a"}
+	mustEqual(t, value.Get(), want)
+}
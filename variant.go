@@ -0,0 +1,375 @@
+package aconfig
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var variantRegistry = struct {
+	sync.RWMutex
+	types map[reflect.Type]map[string]reflect.Type
+}{
+	types: map[reflect.Type]map[string]reflect.Type{},
+}
+
+// RegisterVariant registers a struct type v as a variant of interface I with a given name.
+//
+// A field of type I (or a slice of I) is decoded into a registered type selected by
+// a discriminator key, which is set with `discriminator` tag ("type" by default):
+//
+//	storage: {type: s3, bucket: b}
+//
+// Environment variables and flags work the same way: APP_STORAGE_TYPE=s3 APP_STORAGE_BUCKET=b
+// or -storage.type=s3 -storage.bucket=b, `default` tag sets the variant name.
+// Slices of variants are loaded from files or from JSON in env and flags.
+//
+// Pass a pointer (&S3Config{}) to get a pointer in the field.
+// RegisterVariant panics if I isn't an interface, v isn't a struct or a pointer to it,
+// or the name is already registered for I.
+func RegisterVariant[I any](name string, v I) {
+	iface := reflect.TypeOf((*I)(nil)).Elem()
+	if iface.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("aconfig: variant type %v must be an interface", iface))
+	}
+
+	typ := reflect.TypeOf(v)
+	if typ == nil || (typ.Kind() != reflect.Struct &&
+		(typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct)) {
+		panic(fmt.Sprintf("aconfig: variant %q of %v must be a struct or a pointer to it, got %T", name, iface, v))
+	}
+
+	variantRegistry.Lock()
+	defer variantRegistry.Unlock()
+
+	variants, ok := variantRegistry.types[iface]
+	if !ok {
+		variants = map[string]reflect.Type{}
+		variantRegistry.types[iface] = variants
+	}
+	if _, ok := variants[name]; ok {
+		panic(fmt.Sprintf("aconfig: variant %q of %v is already registered", name, iface))
+	}
+	variants[name] = typ
+}
+
+// lookupVariants returns registered variants of an interface type, nil if there are none.
+func lookupVariants(typ reflect.Type) map[string]reflect.Type {
+	if typ.Kind() != reflect.Interface {
+		return nil
+	}
+	variantRegistry.RLock()
+	defer variantRegistry.RUnlock()
+	return variantRegistry.types[typ]
+}
+
+func hasVariants(typ reflect.Type) bool {
+	return lookupVariants(typ) != nil
+}
+
+// variantValues collects raw values of a variant field from all the sources.
+// The variant is decoded when all the sources are loaded, so env and flags can
+// set fields of a variant selected in a file.
+type variantValues struct {
+	path        string // field path, like Storage.
+	key         string // discriminator key.
+	defaultName string
+
+	file    map[string]any
+	fileTag string
+	fileKey string
+	source  string
+
+	envName  string
+	envs     map[string]string
+	flagName string
+	flags    map[string]string
+
+	value reflect.Value // decoded by the new parser before apply.
+}
+
+func newVariantValues(path, key string) *variantValues {
+	if key == "" {
+		key = "type"
+	}
+	return &variantValues{path: path, key: key}
+}
+
+// setFile merges keys of an object from a file, later files override earlier ones.
+func (vv *variantValues) setFile(file, tag, key string, value any) error {
+	if value == nil {
+		return nil
+	}
+	m, err := mii(value)
+	if err != nil {
+		return fmt.Errorf("%w: want an object, got %T", ErrBadValue, value)
+	}
+
+	// keys of another variant must not leak into the current one.
+	if name, ok := m[vv.key]; ok && vv.file != nil && fmt.Sprint(name) != fmt.Sprint(vv.file[vv.key]) {
+		vv.file = nil
+	}
+	if vv.file == nil {
+		vv.file = map[string]any{}
+	}
+	for k, v := range m {
+		vv.file[k] = v
+	}
+	vv.fileTag, vv.fileKey, vv.source = tag, key, file
+	return nil
+}
+
+// takeFlat moves all the values with a name prefix (APP_STORAGE_ for env) from values.
+func (vv *variantValues) takeFlat(tag, name, delimiter string, values map[string]any) {
+	prefix := name + delimiter
+	taken := map[string]string{}
+	for k, v := range values {
+		if strings.HasPrefix(k, prefix) {
+			taken[k] = fmt.Sprint(v)
+			delete(values, k)
+		}
+	}
+
+	switch tag {
+	case "env":
+		vv.envName, vv.envs = name, taken
+	case "flag":
+		vv.flagName, vv.flags = name, taken
+	}
+}
+
+// variantName returns the variant name, flags have a priority over env, env over files.
+func (vv *variantValues) variantName(cfg Config) string {
+	if name, ok := vv.flags[vv.flagName+cfg.FlagDelimiter+vv.key]; ok {
+		return name
+	}
+	if name, ok := vv.envs[vv.envName+"_"+strings.ToUpper(vv.key)]; ok {
+		return name
+	}
+	if name, ok := vv.file[vv.key]; ok && name != nil {
+		return fmt.Sprint(name)
+	}
+	return vv.defaultName
+}
+
+func (vv *variantValues) isEmpty() bool {
+	return len(vv.file) == 0 && len(vv.envs) == 0 && len(vv.flags) == 0
+}
+
+// decode loads the selected variant of an interface type.
+// Returns an invalid value if nothing is set.
+func (vv *variantValues) decode(cfg Config, profile string, iface reflect.Type) (reflect.Value, error) {
+	name := vv.variantName(cfg)
+	if name == "" {
+		if vv.isEmpty() {
+			return reflect.Value{}, nil
+		}
+		return reflect.Value{}, vv.fieldError(&FieldError{
+			Err: fmt.Errorf("%w: %q key is required to select a variant", ErrBadValue, vv.key),
+		})
+	}
+
+	variants := lookupVariants(iface)
+	typ, ok := variants[name]
+	if !ok {
+		return reflect.Value{}, vv.fieldError(&FieldError{
+			Value: name,
+			Err:   fmt.Errorf("%w: unknown variant %q, want one of %s", ErrBadValue, name, strings.Join(sortedKeys(variants), ", ")),
+		})
+	}
+
+	isPtr := typ.Kind() == reflect.Ptr
+	if isPtr {
+		typ = typ.Elem()
+	}
+	ptr := reflect.New(typ)
+
+	child := newVariantLoader(ptr.Interface(), cfg, vv)
+	if child.errInit != nil {
+		return reflect.Value{}, child.errInit
+	}
+	if err := child.loadVariant(vv, profile); err != nil {
+		return reflect.Value{}, err
+	}
+	if len(child.errs) != 0 {
+		for _, fe := range child.errs {
+			vv.fieldError(fe)
+		}
+		return reflect.Value{}, &LoadError{Errors: child.errs}
+	}
+
+	if isPtr {
+		return ptr, nil
+	}
+	return ptr.Elem(), nil
+}
+
+// fieldError makes a path of a variant field error relative to the config.
+func (vv *variantValues) fieldError(fe *FieldError) *FieldError {
+	switch {
+	case errors.Is(fe.Err, ErrUnknownEnv), errors.Is(fe.Err, ErrUnknownFlag):
+		// full names already.
+	case errors.Is(fe.Err, ErrUnknownField):
+		fe.Path = joinPath(vv.fileKey, fe.Path)
+	default:
+		fe.Path = joinPath(vv.path, fe.Path)
+	}
+	return fe
+}
+
+// newVariantLoader returns a loader for a variant, env and flags are prefixed with the field names.
+func newVariantLoader(dst any, cfg Config, vv *variantValues) *Loader {
+	cfg.NewParser = false
+	cfg.SkipFiles, cfg.SkipDirs = true, true
+	cfg.Files, cfg.Dirs, cfg.AppName = nil, nil, ""
+	cfg.FileFlag, cfg.ProfileEnv, cfg.ProfileFlag = "", "", ""
+
+	// unknown names are checked only with a prefix set by user.
+	cfg.AllowUnknownEnvs = cfg.AllowUnknownEnvs || cfg.EnvPrefix == ""
+	cfg.AllowUnknownFlags = cfg.AllowUnknownFlags || cfg.FlagPrefix == ""
+
+	cfg.EnvPrefix, cfg.FlagPrefix = vv.envName, vv.flagName
+	cfg.SkipEnv = cfg.SkipEnv || vv.envName == ""
+	cfg.SkipFlags = cfg.SkipFlags || vv.flagName == ""
+
+	cfg.Envs = make([]string, 0, len(vv.envs))
+	for _, k := range sortedKeys(vv.envs) {
+		if k != vv.envName+"_"+strings.ToUpper(vv.key) {
+			cfg.Envs = append(cfg.Envs, k+"="+vv.envs[k])
+		}
+	}
+	cfg.Args = make([]string, 0, len(vv.flags))
+	for _, k := range sortedKeys(vv.flags) {
+		if k != vv.flagName+cfg.FlagDelimiter+vv.key {
+			cfg.Args = append(cfg.Args, "-"+k+"="+vv.flags[k])
+		}
+	}
+	return LoaderFor(dst, cfg)
+}
+
+// loadVariant loads defaults, values from a file, env and flags into a variant.
+func (l *Loader) loadVariant(vv *variantValues, profile string) error {
+	l.profile = profile
+
+	if err := l.parseFlags(); err != nil {
+		return err
+	}
+	if !l.config.SkipDefaults {
+		if err := l.loadDefaults(); err != nil {
+			return err
+		}
+	}
+	if vv.file != nil {
+		values := make(map[string]any, len(vv.file))
+		for k, v := range vv.file {
+			if k != vv.key {
+				values[k] = v
+			}
+		}
+		if err := l.applyFile(vv.source, vv.fileTag, values, nil); err != nil {
+			return err
+		}
+	}
+	if !l.config.SkipEnv {
+		if err := l.loadEnvironment(); err != nil {
+			return err
+		}
+	}
+	if !l.config.SkipFlags {
+		if err := l.loadFlags(); err != nil {
+			return err
+		}
+	}
+	l.loadVariants()
+	l.checkRequired()
+	return nil
+}
+
+// loadVariants decodes variant fields from the values collected from all the sources.
+func (l *Loader) loadVariants() {
+	for _, field := range l.fields {
+		vv := field.variant
+		if vv == nil || l.hasError(field.name) {
+			continue
+		}
+
+		value, err := vv.decode(l.config, l.profile, field.value.Type())
+		if err != nil {
+			var fieldErr *FieldError
+			if errors.As(err, &fieldErr) {
+				l.errs = append(l.errs, fieldErr)
+			} else if err := l.collectErrors(err); err != nil {
+				l.addError(field.name, "", nil, err)
+			}
+			continue
+		}
+		if !value.IsValid() {
+			continue
+		}
+		field.value.Set(value)
+		field.isSet = true
+		l.logFieldSet(field, "variant", vv.variantName(l.config))
+	}
+}
+
+// registerVariantFlags registers flags of all the variants of an interface with a flag prefix.
+func registerVariantFlags(flagSet *flag.FlagSet, cfg Config, flagName string, iface reflect.Type, tag reflect.StructTag) {
+	variants := lookupVariants(iface)
+	names := sortedKeys(variants)
+
+	key := flagName + cfg.FlagDelimiter + newVariantValues("", tag.Get("discriminator")).key
+	if flagSet.Lookup(key) == nil {
+		flagSet.String(key, tag.Get("default"), "variant of "+flagName+": "+strings.Join(names, ", "))
+	}
+
+	for _, name := range names {
+		typ := variants[name]
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+
+		vv := &variantValues{flagName: flagName}
+		child := newVariantLoader(reflect.New(typ).Interface(), cfg, vv)
+		if child.errInit != nil {
+			continue // reported on load.
+		}
+		child.flagSet.VisitAll(func(f *flag.Flag) {
+			if flagSet.Lookup(f.Name) == nil {
+				flagSet.String(f.Name, f.DefValue, f.Usage)
+			}
+		})
+	}
+}
+
+// decodeVariant decodes an object from a file or JSON into a variant, used for slice items.
+func decodeVariant(cfg Config, profile string, iface reflect.Type, key string, value any) (reflect.Value, error) {
+	if str, ok := value.(string); ok {
+		if str == "" {
+			return reflect.Value{}, nil
+		}
+		items, err := listItems("[" + str + "]")
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		value = items[0]
+	}
+
+	vv := newVariantValues("", key)
+	if err := vv.setFile("", "json", "", value); err != nil {
+		return reflect.Value{}, err
+	}
+	return vv.decode(cfg, profile, iface)
+}
+
+func joinPath(parent, name string) string {
+	switch {
+	case parent == "":
+		return name
+	case name == "":
+		return parent
+	}
+	return parent + "." + name
+}